@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/payment"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	fakeGatewayCmd = &cobra.Command{
+		Use:   "fake-gateway",
+		Short: "Start a local fake payment gateway",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fakeGatewayCommand()
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			rand.Seed(time.Now().UnixNano())
+		},
+	}
+)
+
+func fakeGatewayCommand() error {
+	listen := viper.GetString("payment.fake_listen_address")
+	gateway := payment.NewFakeGateway(
+		viper.GetString("payment.midtrans_server_key"),
+		viper.GetString("payment.fake_base_url"),
+		viper.GetString("payment.fake_notify_url"),
+	)
+
+	httpServer := &http.Server{
+		Addr:              listen,
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler:           gateway,
+	}
+
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"listen": listen,
+		"notify": viper.GetString("payment.fake_notify_url"),
+	}).Info("fake payment gateway ready")
+
+	done := make(chan os.Signal, 10)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	<-done
+
+	return httpServer.Close()
+}
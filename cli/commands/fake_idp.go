@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/avarian/primbon-ajaib-backend/service/oidc"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	fakeIdpCmd = &cobra.Command{
+		Use:   "fake-idp",
+		Short: "Start a local fake OpenID Connect provider",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fakeIdpCommand()
+		},
+	}
+)
+
+func fakeIdpCommand() error {
+	listen := viper.GetString("oidc.fake.listen_address")
+	provider, err := oidc.NewFakeProvider(
+		viper.GetString("oidc.fake.issuer"),
+		viper.GetString("oidc.fake.client_id"),
+		viper.GetString("oidc.fake.client_secret"),
+	)
+	if err != nil {
+		return err
+	}
+
+	server, err := provider.Start(listen)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"listen": listen,
+		"issuer": provider.Issuer(),
+	}).Info("fake openid provider ready")
+
+	done := make(chan os.Signal, 10)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	<-done
+
+	server.Close()
+	return nil
+}
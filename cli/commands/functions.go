@@ -7,6 +7,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/avarian/primbon-ajaib-backend/service/actor"
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/service/keyring"
+	"github.com/avarian/primbon-ajaib-backend/service/oidc"
+	"github.com/avarian/primbon-ajaib-backend/service/payment"
+	"github.com/avarian/primbon-ajaib-backend/service/storage"
+	"github.com/avarian/primbon-ajaib-backend/service/usage"
+	"github.com/avarian/primbon-ajaib-backend/util"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -83,6 +91,10 @@ func newMysqlDB(profile string) *gorm.DB {
 	if err != nil {
 		logCtx.Fatal(err)
 	}
+	// fill CreatedBy, UpdatedBy and DeletedBy from the request user
+	if err := actor.RegisterCallbacks(db); err != nil {
+		logCtx.Fatal(err)
+	}
 	log.WithField("dsn", dsn).Info("database connected")
 
 	return db
@@ -111,6 +123,10 @@ func newPostgresDB(profile string) *gorm.DB {
 	if err != nil {
 		logCtx.Fatal(err)
 	}
+	// fill CreatedBy, UpdatedBy and DeletedBy from the request user
+	if err := actor.RegisterCallbacks(db); err != nil {
+		logCtx.Fatal(err)
+	}
 	log.WithField("dsn", dsn).Info("database connected")
 
 	return db
@@ -166,3 +182,88 @@ func newS3Session(profile string) *session.Session {
 
 	return s3Session
 }
+
+// Return the private bucket storage, download links are signed for the
+// external s3 url
+func newStorage(profile string, bucket string) *storage.Storage {
+	internal := newS3Session(profile)
+	external := internal.Copy(&aws.Config{Endpoint: aws.String(viper.GetString("s3_external_url"))})
+	return storage.NewStorage(internal, external, bucket)
+}
+
+// Return the payment gateway configured for the profile
+func newPaymentGateway(profile string) payment.Gateway {
+	baseUrl := viper.GetString(profile + ".midtrans_base_url")
+	gateway := payment.NewMidtransGateway(viper.GetString(profile+".midtrans_server_key"), baseUrl)
+
+	log.WithField("url", baseUrl).Info("payment gateway initialized")
+	return gateway
+}
+
+// Return the token pricing table used for usage cost accounting
+func newUsagePricing(key string) usage.Pricing {
+	var prices []usage.Price
+	if err := viper.UnmarshalKey(key, &prices); err != nil {
+		log.WithError(err).Fatal("invalid usage pricing")
+	}
+	return usage.NewPricing(prices)
+}
+
+// Return elastic mailer when an api key is configured, otherwise a dummy mailer
+func newMailer(profile string) util.Mailer {
+	apiKey := viper.GetString(profile + ".elastic_api_key")
+	if apiKey == "" {
+		log.Info("dummy mailer initialized")
+		return util.NewDummyMailer()
+	}
+
+	log.Info("elastic mailer initialized")
+	return util.NewElasticMailer(apiKey, viper.GetString(profile+".elastic_channel"))
+}
+
+// Return infobip messenger when an api key is configured, otherwise a dummy messenger
+func newMessenger(profile string) util.Messenger {
+	apiKey := viper.GetString(profile + ".infobip_api_key")
+	if apiKey == "" {
+		log.Info("dummy messenger initialized")
+		return util.NewDummyMessenger()
+	}
+
+	log.Info("infobip messenger initialized")
+	return util.NewInfobipMessenger(apiKey, viper.GetString(profile+".infobip_callback_url"), viper.GetString(profile+".infobip_sender"))
+}
+
+// Return the jwt keyring, a lone jwt_secret is used as an HS256 key when no
+// keys are configured
+func newKeyring(profile string) *keyring.Keyring {
+	var keys []keyring.Key
+	if err := viper.UnmarshalKey(profile+".keys", &keys); err != nil {
+		log.WithError(err).Fatal("invalid jwt keys")
+	}
+	active := viper.GetString(profile + ".active_kid")
+	if len(keys) == 0 {
+		keys = []keyring.Key{{ID: "default", Algorithm: "HS256", Secret: viper.GetString("jwt_secret")}}
+		active = keys[0].ID
+	}
+
+	ring, err := keyring.NewKeyring(keys, active)
+	if err != nil {
+		log.WithError(err).Fatal("invalid jwt keyring")
+	}
+	log.WithFields(log.Fields{"active_kid": active, "keys": len(keys)}).Info("jwt keyring initialized")
+	return ring
+}
+
+// Return the OpenID Connect login over the configured providers
+func newOidcLogin(profile string, store cache.Store) *oidc.Login {
+	var providers []oidc.ProviderConfig
+	if err := viper.UnmarshalKey(profile+".providers", &providers); err != nil {
+		log.WithError(err).Fatal("invalid oidc providers")
+	}
+	names := make([]string, 0, len(providers))
+	for _, v := range providers {
+		names = append(names, v.Name)
+	}
+	log.WithField("providers", names).Info("oidc login initialized")
+	return oidc.NewLogin(providers, store, time.Duration(viper.GetInt(profile+".state_ttl"))*time.Second)
+}
@@ -17,6 +17,14 @@ func init() {
 	serveCmd.Flags().String("listen", ":8080", "http server listen address")
 	viper.BindPFlag("listen_address", serveCmd.Flags().Lookup("listen"))
 
+	// Command flags for "fake-gateway"
+	fakeGatewayCmd.Flags().String("listen", ":8090", "fake payment gateway listen address")
+	viper.BindPFlag("payment.fake_listen_address", fakeGatewayCmd.Flags().Lookup("listen"))
+
+	// Command flags for "fake-idp"
+	fakeIdpCmd.Flags().String("listen", ":8091", "fake openid provider listen address")
+	viper.BindPFlag("oidc.fake.listen_address", fakeIdpCmd.Flags().Lookup("listen"))
+
 	// Command flags for "queue"
 	//queueCmd.Flags().BoolVar(&queueWorker, "worker", false, "run queue worker (default: "+strconv.FormatBool(queueWorker)+")")
 	workerCmd.Flags().Int("num-goroutines", 4, "number of goroutines")
@@ -31,4 +39,6 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(workerCmd)
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(fakeGatewayCmd)
+	rootCmd.AddCommand(fakeIdpCmd)
 }
@@ -5,7 +5,10 @@ import (
 	"time"
 
 	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 )
 
 var (
@@ -24,10 +27,70 @@ var (
 func migrateCommand() (err error) {
 	// Postgres database
 	db := newMysqlDB("mysql")
+	// Accounts from before email verification existed are taken as verified,
+	// once, when the column is added. Otherwise the limit login policy would
+	// cut every one of them off.
+	backfillEmailVerified := db.Migrator().HasTable(&model.Account{}) && !db.Migrator().HasColumn(&model.Account{}, "EmailVerifiedAt")
+	// Only accounts their owner deleted are purged. Those deleted before the
+	// column existed are told apart by their pending restore link.
+	backfillDeletionRequested := db.Migrator().HasTable(&model.Account{}) && !db.Migrator().HasColumn(&model.Account{}, "DeletionRequestedAt")
 	db.AutoMigrate(
 		&model.Account{},
 		&model.Chatbox{},
 		&model.ChatboxMessage{},
+		&model.Plan{},
+		&model.PaymentOrder{},
+		&model.UsageRecord{},
+		&model.Voucher{},
+		&model.VoucherRedemption{},
+		&model.ReminderLog{},
+		&model.Referral{},
+		&model.VerificationToken{},
+		&model.RefreshToken{},
+		&model.Session{},
+		&model.RecoveryCode{},
+		&model.AuditEvent{},
+		&model.Role{},
+		&model.RolePermission{},
+		&model.AccountRole{},
+		&model.ApiKey{},
+		&model.AccountIdentity{},
 	)
+
+	if backfillEmailVerified {
+		result := db.Unscoped().Model(&model.Account{}).Where("email_verified_at IS NULL").UpdateColumn("email_verified_at", gorm.Expr("created_at"))
+		if result.Error != nil {
+			log.WithError(result.Error).Error("error backfill email verified")
+			return result.Error
+		}
+		log.WithField("accounts", result.RowsAffected).Info("marked existing accounts as email verified")
+	}
+
+	if backfillDeletionRequested {
+		restorable := db.Model(&model.VerificationToken{}).Select("account_id").
+			Where("purpose = ? AND used_at IS NULL", model.TokenPurposeAccountRestore)
+		result := db.Unscoped().Model(&model.Account{}).
+			Where("deleted_at IS NOT NULL AND id IN (?)", restorable).
+			UpdateColumn("deletion_requested_at", gorm.Expr("deleted_at"))
+		if result.Error != nil {
+			log.WithError(result.Error).Error("error backfill deletion requested")
+			return result.Error
+		}
+		log.WithField("accounts", result.RowsAffected).Info("marked deleted accounts for purge")
+	}
+
+	// Accounts without a phone number keep it NULL, the unique index would
+	// let only one of them hold ""
+	result := db.Unscoped().Model(&model.Account{}).Where("phone_number = ?", "").UpdateColumn("phone_number", nil)
+	if result.Error != nil {
+		log.WithError(result.Error).Error("error clear empty phone numbers")
+		return result.Error
+	}
+
+	// Default staff roles
+	if err := rbac.Seed(db); err != nil {
+		log.WithError(err).Error("error seed roles")
+		return err
+	}
 	return nil
 }
@@ -10,10 +10,22 @@ import (
 
 	"github.com/avarian/primbon-ajaib-backend/controllers"
 	"github.com/avarian/primbon-ajaib-backend/delivery/http"
+	"github.com/avarian/primbon-ajaib-backend/jobs"
+	"github.com/avarian/primbon-ajaib-backend/service/apikey"
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/service/lockout"
+	"github.com/avarian/primbon-ajaib-backend/service/otp"
+	"github.com/avarian/primbon-ajaib-backend/service/quota"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/referral"
+	"github.com/avarian/primbon-ajaib-backend/service/session"
+	"github.com/avarian/primbon-ajaib-backend/service/throttle"
+	"github.com/avarian/primbon-ajaib-backend/service/totp"
 	"github.com/avarian/primbon-ajaib-backend/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/taylorchu/work"
 )
 
 var (
@@ -36,25 +48,150 @@ func serveCommand() (err error) {
 	// Mysql database
 	db := newMysqlDB("mysql")
 
-	// Redis client
-	// redis := newRedisClient(viper.GetString("redis.url"))
-	// defer redis.Close()
-	// jobs.SetRedisQueue(work.NewRedisQueue(redis))
+	// Cache store and job queue, redis when configured or in-process memory
+	// (jobs cannot be dispatched without redis)
+	var store cache.Store = cache.NewMemoryStore()
+	// Login counters and token revocations keep working in memory while redis
+	// is unreachable
+	var securityStore cache.Store = store
+	if viper.GetString("cache.driver") == "redis" {
+		redis := newRedisClient(viper.GetString("redis.url"))
+		defer redis.Close()
+		store = cache.NewRedisStore(redis, jobs.Namespace+":")
+		securityStore = cache.NewFallbackStore(store, cache.NewMemoryStore())
+		jobs.SetRedisQueue(work.NewRedisQueue(redis))
+	}
+
+	// Chat quota, free tier limits come from config and paid limits from the plan
+	chatQuota := quota.NewQuota(db, store, quota.Limits{
+		MessagesPerDay: viper.GetInt("quota.free.messages_per_day"),
+		MaxChatboxes:   viper.GetInt("quota.free.max_chatboxes"),
+	})
 
 	// validatorTranslate
 	validator := util.ValidatorTranslate()
 
+	// Request throttling and server side session revocation
+	requestThrottle := throttle.NewThrottle(store)
+	jwtKeys := newKeyring("jwt")
+	accessTokenTTL := time.Duration(viper.GetInt("jwt.access_ttl")) * time.Minute
+	revoker := session.NewRevoker(securityStore, accessTokenTTL)
+	refreshTokens := session.NewRefreshTokens(db, time.Duration(viper.GetInt("jwt.refresh_ttl"))*24*time.Hour, revoker)
+	sessionTracker := session.NewTracker(db, store, time.Duration(viper.GetInt("jwt.last_seen_interval"))*time.Minute)
+
+	// Staff permissions resolved from the roles in the access token
+	authorizer := rbac.NewAuthorizer(db, store, time.Duration(viper.GetInt("rbac.cache_ttl"))*time.Second)
+
+	// Failed login counters per email and client ip
+	loginGuard := lockout.NewGuard(securityStore, lockout.Config{
+		Window: time.Duration(viper.GetInt("lockout.window")) * time.Minute,
+		Email: lockout.Limits{
+			FreeAttempts:     viper.GetInt("lockout.email.free_attempts"),
+			LockoutThreshold: viper.GetInt("lockout.email.threshold"),
+		},
+		IP: lockout.Limits{
+			FreeAttempts:     viper.GetInt("lockout.ip.free_attempts"),
+			LockoutThreshold: viper.GetInt("lockout.ip.threshold"),
+		},
+		BaseDelay:       time.Duration(viper.GetInt("lockout.base_delay")) * time.Second,
+		MaxDelay:        time.Duration(viper.GetInt("lockout.max_delay")) * time.Second,
+		LockoutDuration: time.Duration(viper.GetInt("lockout.duration")) * time.Minute,
+	})
+
+	// Phone otp delivered by SMS
+	phoneOtp := otp.NewOTP(store, newMessenger("messenger"), otp.Config{
+		Secret:      viper.GetString("otp.secret"),
+		Length:      viper.GetInt("otp.length"),
+		TTL:         time.Duration(viper.GetInt("otp.ttl")) * time.Second,
+		MaxAttempts: viper.GetInt("otp.max_attempts"),
+		Cooldown:    time.Duration(viper.GetInt("otp.cooldown")) * time.Second,
+		MaxIssues:   viper.GetInt("otp.max_issues"),
+		IssueWindow: time.Duration(viper.GetInt("otp.issue_window")) * time.Minute,
+	})
+
+	// Password reset links, also sent when an admin forces a reset
+	passwordReset := controllers.PasswordResetConfig{
+		TokenTTL:    time.Duration(viper.GetInt("password_reset.token_ttl")) * time.Minute,
+		ResetUrl:    viper.GetString("password_reset.url"),
+		MaxRequests: viper.GetInt("password_reset.max_requests"),
+		Window:      time.Duration(viper.GetInt("password_reset.window")) * time.Minute,
+	}
+
+	// Referral program
+	referralProgram := referral.NewProgram(viper.GetInt("referral.trial_days"), viper.GetInt("referral.reward_days"))
+
 	//
 	// Initialize Controllers
 	//
 	home := controllers.NewHomeController()
-	account := controllers.NewAccountController(db, validator, viper.GetString("jwt_secret"))
-	openaiChatbox := controllers.NewOpenaiChatboxController(db, validator, viper.GetString("openai_api_key"))
+	account := controllers.NewAccountController(db, validator, controllers.AccountDeps{
+		Keys:     jwtKeys,
+		Referral: referralProgram,
+		EmailVerification: controllers.EmailVerificationConfig{
+			LoginPolicy: viper.GetString("email_verification.login_policy"),
+			TokenTTL:    time.Duration(viper.GetInt("email_verification.token_ttl")) * time.Hour,
+			VerifyUrl:   viper.GetString("app_url") + "/verify-email?token=",
+			ChangeUrl:   viper.GetString("app_url") + "/change-email/confirm?token=",
+		},
+		PasswordReset:  passwordReset,
+		Throttle:       requestThrottle,
+		Revoker:        revoker,
+		OTP:            phoneOtp,
+		RefreshTokens:  refreshTokens,
+		AccessTokenTTL: accessTokenTTL,
+		TwoFactor: controllers.TwoFactorConfig{
+			Issuer:        viper.GetString("two_factor.issuer"),
+			ForceAdmin:    viper.GetBool("two_factor.force_admin"),
+			RecoveryCodes: viper.GetInt("two_factor.recovery_codes"),
+		},
+		TOTP: totp.NewVerifier(store),
+		Challenges: totp.NewChallenges(store,
+			time.Duration(viper.GetInt("two_factor.challenge_ttl"))*time.Second,
+			viper.GetInt("two_factor.max_attempts")),
+		Guard: loginGuard,
+		DataExport: controllers.DataExportConfig{
+			LinkTTL:     time.Duration(viper.GetInt("data_export.link_ttl")) * time.Hour,
+			MaxRequests: viper.GetInt("data_export.max_requests"),
+			Window:      time.Duration(viper.GetInt("data_export.window")) * time.Hour,
+		},
+		AccountDeletion: controllers.AccountDeletionConfig{
+			GracePeriod: time.Duration(viper.GetInt("account_deletion.grace_days")) * 24 * time.Hour,
+			RestoreUrl:  viper.GetString("app_url") + "/account/restore?token=",
+		},
+		Impersonation: controllers.ImpersonationConfig{
+			TTL: time.Duration(viper.GetInt("impersonation.ttl")) * time.Second,
+		},
+		OIDC: newOidcLogin("oidc", store),
+	})
+	adminAccount := controllers.NewAdminAccountController(db, validator, revoker, refreshTokens, passwordReset)
+	role := controllers.NewRoleController(db, validator, authorizer)
+	auditLog := controllers.NewAuditController(db)
+	apiKey := controllers.NewApiKeyController(db, validator)
+	apiKeys := apikey.NewAuthenticator(db, store, requestThrottle, viper.GetInt("api_keys.rate_limit"),
+		time.Duration(viper.GetInt("api_keys.last_used_interval"))*time.Minute)
+	openaiChatbox := controllers.NewOpenaiChatboxController(db, validator, viper.GetString("openai_api_key"), chatQuota, newUsagePricing("usage.pricing"))
+	usage := controllers.NewUsageController(db, validator)
+	voucher := controllers.NewVoucherController(db, validator)
+	payment := controllers.NewPaymentController(db, validator, newPaymentGateway("payment"), referralProgram)
+	referral := controllers.NewReferralController(db, validator)
 
 	server := http.NewServer(viper.GetString("listen_address"),
 		home,
 		account,
+		adminAccount,
+		role,
+		auditLog,
+		apiKey,
 		openaiChatbox,
+		payment,
+		usage,
+		voucher,
+		referral,
+		jwtKeys,
+		revoker,
+		sessionTracker,
+		authorizer,
+		apiKeys,
 	)
 
 	//
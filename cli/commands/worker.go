@@ -47,6 +47,16 @@ func newWorker(client *redis.Client) *work.Worker {
 		},
 	})
 
+	jobOptions := &work.JobOptions{
+		MaxExecutionTime: maxExecutionTime,
+		IdleWait:         idleWait,
+		NumGoroutines:    numGoroutines,
+		HandleMiddleware: []work.HandleMiddleware{
+			logrus.HandleFuncLogger,
+			discard.MaxRetry(maxRetry),
+		},
+	}
+
 	//
 	// Register job handlers
 	//
@@ -62,15 +72,133 @@ func newWorker(client *redis.Client) *work.Worker {
 		}
 
 		return nil
-	}, &work.JobOptions{
-		MaxExecutionTime: maxExecutionTime,
-		IdleWait:         idleWait,
-		NumGoroutines:    numGoroutines,
-		HandleMiddleware: []work.HandleMiddleware{
-			logrus.HandleFuncLogger,
-			discard.MaxRetry(maxRetry),
-		},
-	})
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.PremiumReminderJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var reminder jobs.PremiumReminderJob
+
+		if err := j.UnmarshalJSONPayload(&reminder); err != nil {
+			return err
+		}
+
+		return reminder.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.VerifyEmailJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var verifyEmail jobs.VerifyEmailJob
+
+		if err := j.UnmarshalJSONPayload(&verifyEmail); err != nil {
+			return err
+		}
+
+		return verifyEmail.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.ResetPasswordJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var resetPassword jobs.ResetPasswordJob
+
+		if err := j.UnmarshalJSONPayload(&resetPassword); err != nil {
+			return err
+		}
+
+		return resetPassword.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.AccountLockedJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var accountLocked jobs.AccountLockedJob
+
+		if err := j.UnmarshalJSONPayload(&accountLocked); err != nil {
+			return err
+		}
+
+		return accountLocked.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.ChangeEmailJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var changeEmail jobs.ChangeEmailJob
+
+		if err := j.UnmarshalJSONPayload(&changeEmail); err != nil {
+			return err
+		}
+
+		return changeEmail.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.ContactChangedJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var contactChanged jobs.ContactChangedJob
+
+		if err := j.UnmarshalJSONPayload(&contactChanged); err != nil {
+			return err
+		}
+
+		return contactChanged.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.DataExportJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var dataExport jobs.DataExportJob
+
+		if err := j.UnmarshalJSONPayload(&dataExport); err != nil {
+			return err
+		}
+
+		return dataExport.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.AccountDeletedJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var accountDeleted jobs.AccountDeletedJob
+
+		if err := j.UnmarshalJSONPayload(&accountDeleted); err != nil {
+			return err
+		}
+
+		return accountDeleted.Handle(ctx)
+	}, jobOptions)
+
+	if err != nil {
+		log.WithError(err).Fatal("fail to register queue job handler")
+	}
+
+	err = w.RegisterWithContext(jobs.AccountPurgeJobQueueId, func(ctx context.Context, j *work.Job, do *work.DequeueOptions) error {
+		var accountPurge jobs.AccountPurgeJob
+
+		if err := j.UnmarshalJSONPayload(&accountPurge); err != nil {
+			return err
+		}
+
+		return accountPurge.Handle(ctx)
+	}, jobOptions)
 
 	if err != nil {
 		log.WithError(err).Fatal("fail to register queue job handler")
@@ -92,16 +220,55 @@ func workerCommand() error {
 	defer redis.Close()
 	log.WithField("url", viper.GetString("redis.url")).Info("redis client initialized")
 
+	jobs.SetRedisQueue(work.NewRedisQueue(redis))
+
+	// Services used by job handlers
+	jobs.SetDB(newMysqlDB("mysql"))
+	jobs.SetMailer(newMailer("mailer"), jobs.MailFrom{
+		Name:    viper.GetString("mailer.from_name"),
+		Address: viper.GetString("mailer.from"),
+	})
+	jobs.SetMessenger(newMessenger("messenger"))
+	jobs.SetStorage(newStorage("s3", viper.GetString("data_export.bucket")))
+
 	w := newWorker(redis)
 	w.Start()
 
+	stopSchedule := make(chan struct{})
+	go schedule(stopSchedule, time.Duration(viper.GetInt("reminder.interval"))*time.Minute, func() {
+		jobs.Dispatch(jobs.NewPremiumReminderJob())
+	})
+	go schedule(stopSchedule, time.Duration(viper.GetInt("account_deletion.purge_interval"))*time.Minute, func() {
+		jobs.Dispatch(jobs.NewAccountPurgeJob(viper.GetInt("account_deletion.grace_days")))
+	})
+
 	done := make(chan os.Signal, 10)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	<-done
 
 	log.Info("stopping workers...")
+	close(stopSchedule)
 	w.Stop()
 	log.Info("all workers stopped")
 
 	return nil
 }
+
+// Run fn right away and then on every interval until stop is closed
+func schedule(stop chan struct{}, interval time.Duration, fn func()) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fn()
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-stop:
+			return
+		}
+	}
+}
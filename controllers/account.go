@@ -2,11 +2,27 @@ package controllers
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/avarian/primbon-ajaib-backend/controllers/view"
+	"github.com/avarian/primbon-ajaib-backend/jobs"
 	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/keyring"
+	"github.com/avarian/primbon-ajaib-backend/service/lockout"
+	"github.com/avarian/primbon-ajaib-backend/service/oidc"
+	"github.com/avarian/primbon-ajaib-backend/service/otp"
+	"github.com/avarian/primbon-ajaib-backend/service/referral"
 	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/session"
+	"github.com/avarian/primbon-ajaib-backend/service/throttle"
+	"github.com/avarian/primbon-ajaib-backend/service/totp"
+	"github.com/avarian/primbon-ajaib-backend/service/verification"
 	"github.com/avarian/primbon-ajaib-backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -17,11 +33,12 @@ import (
 )
 
 type PostRegisterRequest struct {
-	Name        string `json:"name"  validate:"required"`
-	Email       string `json:"email"  validate:"required,email"`
-	PhoneNumber string `json:"phone_number"  validate:"required"`
-	Password    string `json:"password"  validate:"required"`
-	Address     string `json:"address"  validate:"required"`
+	Name         string `json:"name"  validate:"required"`
+	Email        string `json:"email"  validate:"required,email"`
+	PhoneNumber  string `json:"phone_number"  validate:"required"`
+	Password     string `json:"password"  validate:"required"`
+	Address      string `json:"address"  validate:"required"`
+	ReferralCode string `json:"referral_code"`
 }
 
 type PostLoginRequest struct {
@@ -30,29 +47,160 @@ type PostLoginRequest struct {
 }
 
 type PostChangePasswordRequest struct {
-	OldPassword string `json:"old_password"  validate:"required"`
+	OldPassword   string `json:"old_password"  validate:"required"`
+	NewPassword   string `json:"new_password"  validate:"required"`
+	SignOutOthers bool   `json:"sign_out_others"`
+}
+
+type PostForgotPasswordRequest struct {
+	Email       string `json:"email"  validate:"required_without=PhoneNumber,omitempty,email"`
+	PhoneNumber string `json:"phone_number"  validate:"required_without=Email"`
+}
+
+type PostResetPasswordRequest struct {
+	Token       string `json:"token"  validate:"required"`
 	NewPassword string `json:"new_password"  validate:"required"`
 }
 
+type PutReminderPreferenceRequest struct {
+	OptOut *bool `json:"opt_out"  validate:"required"`
+}
+
 type JWTClaim struct {
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Type      string `json:"type"`
-	IsPremium bool   `json:"is_premium"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	Type          string   `json:"type"`
+	EmailVerified bool     `json:"email_verified"`
+	SessionID     string   `json:"sid"`
+	TwoFactor     bool     `json:"tfa"`
+	Roles         []string `json:"roles,omitempty"`
+	// Set on impersonation tokens, names the admin acting as the user
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.StandardClaims
 }
 
+// ActorClaim is the RFC 8693 "act" claim
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
+// Login policies for accounts that have not verified their email
+const (
+	LoginPolicyAllow  = "allow"
+	LoginPolicyLimit  = "limit"
+	LoginPolicyRefuse = "refuse"
+)
+
+type EmailVerificationConfig struct {
+	LoginPolicy string
+	TokenTTL    time.Duration
+	// Links sent by email, the token is appended to them
+	VerifyUrl string
+	ChangeUrl string
+}
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration
+	// Link sent by email or SMS, the token is appended to it
+	ResetUrl string
+	// Reset requests allowed per identity in Window
+	MaxRequests int
+	Window      time.Duration
+}
+
+type TwoFactorConfig struct {
+	// Issuer shown by authenticator apps
+	Issuer string
+	// Admin routes require a token that passed the second factor
+	ForceAdmin    bool
+	RecoveryCodes int
+}
+
+type DataExportConfig struct {
+	// Lifetime of the emailed download link
+	LinkTTL time.Duration
+	// Export requests allowed per account in Window
+	MaxRequests int
+	Window      time.Duration
+}
+
+type AccountDeletionConfig struct {
+	// Time a deleted account can be restored before it is purged
+	GracePeriod time.Duration
+	// Link sent by email, the token is appended to it
+	RestoreUrl string
+}
+
+type ImpersonationConfig struct {
+	// Lifetime of an impersonation token, it can not be refreshed
+	TTL time.Duration
+}
+
 type AccountController struct {
-	db        *gorm.DB
-	validator *util.Validator
-	jwtSecret string
+	db                *gorm.DB
+	validator         *util.Validator
+	keys              *keyring.Keyring
+	referral          *referral.Program
+	emailVerification EmailVerificationConfig
+	passwordReset     PasswordResetConfig
+	throttle          *throttle.Throttle
+	revoker           *session.Revoker
+	otp               *otp.OTP
+	refreshTokens     *session.RefreshTokens
+	accessTokenTTL    time.Duration
+	twoFactor         TwoFactorConfig
+	totp              *totp.Verifier
+	challenges        *totp.Challenges
+	guard             *lockout.Guard
+	dataExport        DataExportConfig
+	accountDeletion   AccountDeletionConfig
+	impersonation     ImpersonationConfig
+	oidc              *oidc.Login
+}
+
+// AccountDeps are the services and settings the account controller needs
+// on top of the database and validator.
+type AccountDeps struct {
+	Keys              *keyring.Keyring
+	Referral          *referral.Program
+	EmailVerification EmailVerificationConfig
+	PasswordReset     PasswordResetConfig
+	Throttle          *throttle.Throttle
+	Revoker           *session.Revoker
+	OTP               *otp.OTP
+	RefreshTokens     *session.RefreshTokens
+	AccessTokenTTL    time.Duration
+	TwoFactor         TwoFactorConfig
+	TOTP              *totp.Verifier
+	Challenges        *totp.Challenges
+	Guard             *lockout.Guard
+	DataExport        DataExportConfig
+	AccountDeletion   AccountDeletionConfig
+	Impersonation     ImpersonationConfig
+	OIDC              *oidc.Login
 }
 
-func NewAccountController(db *gorm.DB, validator *util.Validator, jwtSecret string) *AccountController {
+func NewAccountController(db *gorm.DB, validator *util.Validator, deps AccountDeps) *AccountController {
 	return &AccountController{
-		db:        db,
-		validator: validator,
-		jwtSecret: jwtSecret,
+		db:                db,
+		validator:         validator,
+		keys:              deps.Keys,
+		referral:          deps.Referral,
+		emailVerification: deps.EmailVerification,
+		passwordReset:     deps.PasswordReset,
+		throttle:          deps.Throttle,
+		revoker:           deps.Revoker,
+		otp:               deps.OTP,
+		refreshTokens:     deps.RefreshTokens,
+		accessTokenTTL:    deps.AccessTokenTTL,
+		twoFactor:         deps.TwoFactor,
+		totp:              deps.TOTP,
+		challenges:        deps.Challenges,
+		guard:             deps.Guard,
+		dataExport:        deps.DataExport,
+		accountDeletion:   deps.AccountDeletion,
+		impersonation:     deps.Impersonation,
+		oidc:              deps.OIDC,
 	}
 }
 
@@ -92,25 +240,55 @@ func (s *AccountController) PostRegister(c *gin.Context) {
 		return
 	}
 
+	referralCode, err := referral.GenerateCode()
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error generate referral code")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	account := model.Account{
-		Address:     req.Address,
-		Email:       req.Email,
-		Name:        req.Name,
-		PhoneNumber: req.PhoneNumber,
-		Password:    string(hashedPassword),
+		Address:      req.Address,
+		Email:        req.Email,
+		Name:         req.Name,
+		PhoneNumber:  &req.PhoneNumber,
+		Password:     string(hashedPassword),
+		ReferralCode: &referralCode,
 	}
 
-	accountRepo := repository.NewAccountRepository(s.db)
-	account, result := accountRepo.Create(account)
-	if result.Error != nil {
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		account, result = repository.NewAccountRepository(tx).Create(account)
+		if result.Error != nil {
+			return result.Error
+		}
+		if req.ReferralCode != "" {
+			if _, err := s.referral.Attach(tx, account, req.ReferralCode); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		logCtx.WithField("reason", err).Error("error create account")
-		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+		if repository.IsDuplicateEntry(err) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "email or phone number already registered"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		return
 	}
 
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, _ = accountRepo.OneById(int(account.ID))
+
+	if err := s.sendVerificationEmail(c, account); err != nil {
+		logCtx.WithField("reason", err).Error("error send verification email")
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Sucess!",
-		"data":    account,
+		"data":    view.NewAccount(account),
 	})
 }
 
@@ -143,7 +321,11 @@ func (s *AccountController) PostLogin(c *gin.Context) {
 		"api":   "PostLogin",
 	})
 
-	accountRepo := repository.NewAccountRepository(s.db)
+	if s.loginBlocked(c, logCtx, req.Email) {
+		return
+	}
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
 	account, result := accountRepo.OneByEmail(req.Email)
 	if result.Error != nil || result.RowsAffected == 0 {
 		err := errors.New("not found")
@@ -151,41 +333,130 @@ func (s *AccountController) PostLogin(c *gin.Context) {
 			err = result.Error
 		}
 		logCtx.WithField("reason", err).Error("error find account")
-		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		s.loginFailed(c, logCtx, req.Email, nil)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
 		// If the two passwords don't match, return a 401 status
 		logCtx.WithField("reason", err).Error("error compare password")
-		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		s.loginFailed(c, logCtx, req.Email, &account)
 		return
 	}
 
-	isPremium := false
-	if time.Now().Before(time.Time(account.ValidUntil)) {
-		isPremium = true
+	s.loginSucceeded(c, logCtx, account)
+}
+
+// Clear the failures of a first factor that passed and respond. With two
+// factor on they stay until the second factor passes too, so the password
+// alone can not buy fresh guesses at the code.
+func (s *AccountController) loginSucceeded(c *gin.Context, logCtx *log.Entry, account model.Account) {
+	if account.TotpEnabledAt == nil {
+		if err := s.guard.Succeed(c.Request.Context(), account.Email); err != nil {
+			logCtx.WithField("reason", err).Error("error reset login failures")
+		}
+	}
+	s.respondLogin(c, logCtx, account)
+}
+
+// Abort when the email or the client IP is held back by the lockout guard.
+// A guard error lets the attempt through.
+func (s *AccountController) loginBlocked(c *gin.Context, logCtx *log.Entry, email string) bool {
+	decision, err := s.guard.Check(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error check login lockout")
+		return false
 	}
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
-	claims := &JWTClaim{
-		Email:     account.Email,
-		Username:  account.Email,
-		Type:      account.Type,
-		IsPremium: isPremium,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	if decision.Allowed {
+		return false
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	logCtx.WithFields(log.Fields{
+		"security_event": "login_blocked",
+		"ip_address":     c.ClientIP(),
+		"locked":         decision.Locked,
+	}).Warn("login attempt blocked")
+	message := "too many failed attempts, try again later"
+	if decision.Locked {
+		message = "account temporarily locked, try again later"
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+	return true
+}
+
+// Count a failed password, otp or second factor, lock the account past the
+// threshold and tell its owner. Failures for unknown emails are counted the
+// same way.
+func (s *AccountController) loginFailed(c *gin.Context, logCtx *log.Entry, email string, account *model.Account) {
+	failure, err := s.guard.Fail(c.Request.Context(), email, c.ClientIP())
 	if err != nil {
-		logCtx.WithField("reason", err).Error("error generate jwt")
-		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		logCtx.WithField("reason", err).Error("error count login failure")
+	}
+	logCtx = logCtx.WithFields(log.Fields{
+		"ip_address":     c.ClientIP(),
+		"email_failures": failure.EmailFailures,
+		"ip_failures":    failure.IPFailures,
+	})
+	logCtx.WithField("security_event", "login_failed").Warn("login failed")
+	s.recordLogin(c, logCtx, audit.ActionLoginFailure, email, account)
+
+	if failure.IPLocked {
+		logCtx.WithField("security_event", "ip_locked").Warn("client ip locked out")
+	}
+	if failure.EmailLocked {
+		logCtx.WithField("security_event", "account_locked").Warn("account locked out")
+		if account != nil {
+			minutes := int(s.guard.LockoutDuration().Minutes())
+			if err := jobs.Dispatch(jobs.NewAccountLockedJob(account.Name, account.Email, c.ClientIP(), minutes)); err != nil {
+				logCtx.WithField("reason", err).Error("error dispatch account locked")
+			}
+		}
+	}
+
+	if failure.Delay > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(failure.Delay.Seconds()))))
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+}
+
+// UnlockAccount	goDocs
+// @Summary      unlock an account
+// @Description  lift a login lockout and clear the failed login count of an account
+// @Tags         Account
+// @Produce      application/json
+// @Param        id path int true "Account ID"
+// @Router       /admin/accounts/{id}/unlock [post]
+func (s *AccountController) PostUnlockAccount(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostUnlockAccount",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
 		return
 	}
 
+	if err := s.guard.Unlock(c.Request.Context(), account.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error unlock account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error unlock account"})
+		return
+	}
+	if err := audit.Record(s.db.WithContext(c.Request.Context()), auditEvent(c, audit.ActionAccountUnlock, account), nil); err != nil {
+		logCtx.WithField("reason", err).Error("error record audit event")
+	}
+	logCtx.WithFields(log.Fields{
+		"security_event": "account_unlocked",
+		"email":          account.Email,
+	}).Warn("account unlocked by admin")
+
 	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
+		"message": "Success!",
 	})
 }
 
@@ -211,7 +482,7 @@ func (s *AccountController) PostChangePassword(c *gin.Context) {
 	})
 
 	username := c.GetString("username")
-	accountRepo := repository.NewAccountRepository(s.db)
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
 	account, result := accountRepo.OneByEmail(username)
 	if result.Error != nil || result.RowsAffected == 0 {
 		err := errors.New("error find account")
@@ -243,9 +514,329 @@ func (s *AccountController) PostChangePassword(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
 		return
 	}
+	recordAuditEvent(s.db, c, logCtx, auditEvent(c, audit.ActionPasswordChange, account), nil)
+
+	if req.SignOutOthers {
+		familyIds, err := s.refreshTokens.RevokeAccount(account.ID, c.GetString("session_id"))
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error revoke other sessions")
+		}
+		s.revokeSessions(c, logCtx, familyIds)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sucess!",
+		"data":    view.NewAccount(account),
+	})
+}
+
+// ReminderPreference	goDocs
+// @Summary      opt in or out of premium expiry reminders
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PutReminderPreferenceRequest true "Body Request"
+// @Router       /me/reminders [put]
+func (s *AccountController) PutReminderPreference(c *gin.Context) {
+	// bind data
+	var req PutReminderPreferenceRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PutReminderPreference",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	// AssignData skips false, so the flag is written explicitly
+	if result := s.db.WithContext(c.Request.Context()).Model(&account).Update("reminder_opt_out", *req.OptOut); result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"reminder_opt_out": account.ReminderOptOut,
+		},
+	})
+}
+
+// VerifyEmail	goDocs
+// @Summary      verify an email address
+// @Description  activate the account with the single-use token sent by email
+// @Tags         Account
+// @Produce      application/json
+// @Param        token query string true "verification token"
+// @Router       /verify-email [get]
+func (s *AccountController) GetVerifyEmail(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetVerifyEmail",
+	})
+
+	token := c.Query("token")
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "token is required"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		verificationToken, err := verification.Consume(tx, model.TokenPurposeEmailVerification, token)
+		if err != nil {
+			return err
+		}
+
+		accountRepo := repository.NewAccountRepository(tx)
+		account, result := accountRepo.OneById(int(verificationToken.AccountID))
+		if result.Error != nil {
+			return result.Error
+		}
+		// the email changed after the token was issued
+		if result.RowsAffected == 0 || account.Email != verificationToken.Target {
+			return verification.ErrInvalidToken
+		}
+		if account.EmailVerifiedAt != nil {
+			return nil
+		}
+
+		now := time.Now()
+		_, result = accountRepo.Update(int(account.ID), model.Account{EmailVerifiedAt: &now})
+		return result.Error
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error verify email")
+		if errors.Is(err, verification.ErrInvalidToken) {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// ResendVerifyEmail	goDocs
+// @Summary      resend the verification email
+// @Tags         Account
+// @Produce      application/json
+// @Router       /verify-email/resend [post]
+func (s *AccountController) PostResendVerifyEmail(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PostResendVerifyEmail",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.EmailVerifiedAt != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "email already verified"})
+		return
+	}
+
+	if err := s.sendVerificationEmail(c, account); err != nil {
+		logCtx.WithField("reason", err).Error("error send verification email")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+func (s *AccountController) sendVerificationEmail(c *gin.Context, account model.Account) error {
+	token, err := verification.Issue(s.db.WithContext(c.Request.Context()), account.ID, model.TokenPurposeEmailVerification, account.Email, s.emailVerification.TokenTTL)
+	if err != nil {
+		return err
+	}
+	link := s.emailVerification.VerifyUrl + url.QueryEscape(token)
+	return jobs.Dispatch(jobs.NewVerifyEmailJob(account.Name, account.Email, link))
+}
+
+// ForgotPassword	goDocs
+// @Summary      request a password reset
+// @Description  send a single-use reset link by email or SMS, the response never reveals whether the account exists
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostForgotPasswordRequest true "Body Request"
+// @Router       /forgot-password [post]
+func (s *AccountController) PostForgotPassword(c *gin.Context) {
+	// bind data
+	var req PostForgotPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	identity := strings.ToLower(req.Email)
+	if identity == "" {
+		identity = req.PhoneNumber
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"identity": identity,
+		"api":      "PostForgotPassword",
+	})
+
+	allowed, err := s.throttle.Hit(c.Request.Context(), "forgot_password:"+identity, s.passwordReset.MaxRequests, s.passwordReset.Window)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error throttle")
+	} else if !allowed {
+		logCtx.Warn("too many reset requests")
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+		return
+	}
+
+	// from here on every outcome gets the same response
+	response := gin.H{
+		"message": "If the account exists, a reset link has been sent.",
+	}
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	var account model.Account
+	var result *gorm.DB
+	if req.Email != "" {
+		account, result = accountRepo.OneByEmail(req.Email)
+	} else {
+		// only a confirmed number may reset the password, anyone could have
+		// typed in a number they do not own
+		account, result = accountRepo.OneByVerifiedPhoneNumber(req.PhoneNumber)
+	}
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Info("reset requested for unknown account")
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := verification.Issue(s.db.WithContext(c.Request.Context()), account.ID, model.TokenPurposePasswordReset, "", s.passwordReset.TokenTTL)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error issue reset token")
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := s.passwordReset.ResetUrl + url.QueryEscape(token)
+	job := jobs.NewResetPasswordJob(account.Name, account.Email, "", link)
+	if req.Email == "" {
+		job = jobs.NewResetPasswordJob(account.Name, "", account.Phone(), link)
+	}
+	if err := jobs.Dispatch(job); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch reset password")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword	goDocs
+// @Summary      reset the password
+// @Description  set a new password with a reset token and sign out every session
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostResetPasswordRequest true "Body Request"
+// @Router       /reset-password [post]
+func (s *AccountController) PostResetPassword(c *gin.Context) {
+	// bind data
+	var req PostResetPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PostResetPassword",
+	})
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), 5)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error hash password")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var account model.Account
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		resetToken, err := verification.Consume(tx, model.TokenPurposePasswordReset, req.Token)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var result *gorm.DB
+		account, result = repository.NewAccountRepository(tx).Update(int(resetToken.AccountID), model.Account{
+			Password:          string(hashedPassword),
+			PasswordChangedAt: &now,
+		})
+		return result.Error
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error reset password")
+		if errors.Is(err, verification.ErrInvalidToken) {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error reset password"})
+		return
+	}
+	// the reset link proves the owner is acting
+	event := auditEvent(c, audit.ActionPasswordChange, account)
+	event.Actor = account.Email
+	recordAuditEvent(s.db, c, logCtx, event, audit.Changes{"via": {To: "reset_password"}})
+
+	if err := s.revoker.RevokeAll(c.Request.Context(), account.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke sessions")
+	}
+	if _, err := s.refreshTokens.RevokeAccount(account.ID, ""); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke refresh tokens")
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Sucess!",
-		"data":    account,
 	})
 }
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Impersonate	goDocs
+// @Summary      sign in as a customer
+// @Description  issue a short lived access token for the account, marked with an act claim naming the admin; it has no refresh token and can not change the password, pay or delete the account
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "Account ID"
+// @Router       /admin/accounts/{id}/impersonate [post]
+func (s *AccountController) PostImpersonate(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostImpersonate",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	account, result := repository.NewAccountRepository(s.db.WithContext(c.Request.Context())).OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.Email == c.GetString("username") {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": errAdminSelf.Error()})
+		return
+	}
+	if s.refuseSuspended(c, logCtx, account) {
+		return
+	}
+	// staff accounts are off limits, impersonating them would lend their roles
+	roles, err := rbac.RolesOf(s.db.WithContext(c.Request.Context()), account.ID)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find roles")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error impersonate"})
+		return
+	}
+	if len(roles) > 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "staff accounts can not be impersonated"})
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.impersonation.TTL)
+	claims := &JWTClaim{
+		Email:         account.Email,
+		Username:      account.Email,
+		Type:          account.Type,
+		EmailVerified: account.EmailVerifiedAt != nil,
+		Act:           &ActorClaim{Subject: c.GetString("username")},
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+
+	// no audit event, no token
+	if err := audit.Record(s.db.WithContext(c.Request.Context()), auditEvent(c, audit.ActionImpersonationStart, account), audit.Changes{
+		"jti":        {To: claims.Id},
+		"expires_at": {To: expiresAt},
+	}); err != nil {
+		logCtx.WithField("reason", err).Error("error record audit event")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error impersonate"})
+		return
+	}
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error generate jwt")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error impersonate"})
+		return
+	}
+	logCtx.WithFields(log.Fields{
+		"security_event": "impersonation_started",
+		"account_id":     account.ID,
+	}).Warn("admin impersonating account")
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokenString,
+		"expires_in":    int(s.impersonation.TTL.Seconds()),
+		"impersonating": account.Email,
+	})
+}
+
+// EndImpersonation	goDocs
+// @Summary      end an impersonation
+// @Description  revoke the impersonation token in use, the admin keeps their own session
+// @Tags         Account
+// @Produce      application/json
+// @Router       /impersonation/end [post]
+func (s *AccountController) PostEndImpersonation(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username":     c.GetString("username"),
+		"impersonator": c.GetString("impersonator"),
+		"api":          "PostEndImpersonation",
+	})
+
+	if c.GetString("impersonator") == "" {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "not impersonating"})
+		return
+	}
+
+	if err := s.revoker.RevokeToken(c.Request.Context(), c.GetString("jti"), c.GetInt64("expires_at")); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke impersonation token")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error end impersonation"})
+		return
+	}
+
+	event := newAuditEvent(c, audit.ActionImpersonationEnd, model.AuditTargetAccount, nil)
+	account, result := repository.NewAccountRepository(s.db.WithContext(c.Request.Context())).OneByEmail(c.GetString("username"))
+	if result.Error == nil && result.RowsAffected > 0 {
+		event.TargetID = &account.ID
+	}
+	recordAuditEvent(s.db, c, logCtx, event, audit.Changes{
+		"jti": {From: c.GetString("jti")},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/oidc"
+	"github.com/avarian/primbon-ajaib-backend/service/referral"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/login/oidc"
+)
+
+var (
+	errOidcEmailNotVerified = errors.New("the provider did not verify this email")
+	errOidcLinkUnverified   = errors.New("an account with this email exists, log in with its password and verify the email first")
+	errOidcAccountGone      = errors.New("linked account not found")
+)
+
+// OidcLogin	goDocs
+// @Summary      log in with an OpenID provider
+// @Description  redirect to the provider sign in page and set the oidc_state cookie; the provider sends the user back to the callback
+// @Tags         Account
+// @Param        provider path string true "provider name, see oidc.providers"
+// @Router       /login/oidc/{provider} [get]
+func (s *AccountController) GetOidcLogin(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"provider": c.Param("provider"),
+		"api":      "GetOidcLogin",
+	})
+
+	authUrl, state, err := s.oidc.AuthURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error start oidc login")
+		if errors.Is(err, oidc.ErrUnknownProvider) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "error login"})
+		return
+	}
+
+	// Lax, the callback is a top level navigation back from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(s.oidc.StateTTL().Seconds()), oidcCookiePath, "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, authUrl)
+}
+
+// OidcCallback	goDocs
+// @Summary      finish a login with an OpenID provider
+// @Description  needs the oidc_state cookie of the browser that started the login; log in the account linked to the provider user, or link one by verified email, or create one; responds like /login
+// @Tags         Account
+// @Produce      application/json
+// @Param        provider path string true "provider name"
+// @Param        code query string true "authorization code"
+// @Param        state query string true "login state"
+// @Router       /login/oidc/{provider}/callback [get]
+func (s *AccountController) GetOidcCallback(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"provider": c.Param("provider"),
+		"api":      "GetOidcCallback",
+	})
+
+	if providerError := c.Query("error"); providerError != "" {
+		logCtx.WithField("reason", providerError).Warn("provider refused login")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": providerError})
+		return
+	}
+
+	// the state must come back to the browser that started the login,
+	// otherwise anyone could sign a victim into their own account
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", secureRequest(c), true)
+	if cookieState == "" || subtle.ConstantTimeCompare([]byte(cookieState), []byte(c.Query("state"))) != 1 {
+		logCtx.Warn("oidc state does not match the browser")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": oidc.ErrInvalidState.Error()})
+		return
+	}
+
+	identity, err := s.oidc.Exchange(c.Request.Context(), c.Param("provider"), c.Query("code"), c.Query("state"))
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error finish oidc login")
+		switch {
+		case errors.Is(err, oidc.ErrUnknownProvider):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, oidc.ErrInvalidState), errors.Is(err, oidc.ErrInvalidIDToken):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "error login"})
+		}
+		return
+	}
+	logCtx = logCtx.WithField("email", identity.Email)
+
+	account, err := s.oidcAccount(c, identity)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find oidc account")
+		switch {
+		case errors.Is(err, errOidcAccountGone):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		case errors.Is(err, errOidcEmailNotVerified):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, errOidcLinkUnverified), repository.IsDuplicateEntry(err):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": errOidcLinkUnverified.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+		}
+		return
+	}
+
+	s.respondLogin(c, logCtx, account)
+}
+
+// Find the account of a provider user: by an earlier link, else by
+// verified email, else a new account. Linking by email needs both the
+// provider and us to have verified it, so nobody can claim an account by
+// registering its email elsewhere first.
+func (s *AccountController) oidcAccount(c *gin.Context, identity oidc.Identity) (model.Account, error) {
+	db := s.db.WithContext(c.Request.Context())
+	link, result := repository.NewAccountIdentityRepository(db).OneByProviderSubject(identity.Provider, identity.Subject)
+	if result.Error != nil {
+		return model.Account{}, result.Error
+	}
+	if result.RowsAffected > 0 {
+		account, result := repository.NewAccountRepository(db).OneById(int(link.AccountID))
+		if result.Error == nil && result.RowsAffected == 0 {
+			return account, errOidcAccountGone
+		}
+		return account, result.Error
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return model.Account{}, errOidcEmailNotVerified
+	}
+	account, result := repository.NewAccountRepository(db).OneByEmail(identity.Email)
+	if result.Error != nil {
+		return account, result.Error
+	}
+	if result.RowsAffected > 0 && account.EmailVerifiedAt == nil {
+		return account, errOidcLinkUnverified
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if account.ID == 0 {
+			var err error
+			if account, err = newOidcAccount(tx, identity); err != nil {
+				return err
+			}
+		}
+		_, result := repository.NewAccountIdentityRepository(tx).Create(model.AccountIdentity{
+			AccountID: account.ID,
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+		})
+		return result.Error
+	})
+	return account, err
+}
+
+// Accounts created by social login get an unusable random password; the
+// owner can set one through forgot password.
+func newOidcAccount(tx *gorm.DB, identity oidc.Identity) (model.Account, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), 5)
+	if err != nil {
+		return model.Account{}, err
+	}
+	referralCode, err := referral.GenerateCode()
+	if err != nil {
+		return model.Account{}, err
+	}
+	name := identity.Name
+	if name == "" {
+		name = identity.Email
+	}
+	now := time.Now()
+	account, result := repository.NewAccountRepository(tx).Create(model.Account{
+		Name:            name,
+		Email:           identity.Email,
+		Password:        string(hashedPassword),
+		Type:            model.AccountTypeCustomer,
+		EmailVerifiedAt: &now,
+		ReferralCode:    &referralCode,
+	})
+	return account, result.Error
+}
+
+// Served over TLS, directly or behind a proxy that terminates it
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
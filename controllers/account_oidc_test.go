@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/oidc"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newOidcTestController(t *testing.T) (*AccountController, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&model.Account{}, &model.AccountIdentity{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return &AccountController{db: db}, db
+}
+
+func newOidcTestContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/login/oidc/fake/callback", nil)
+	return c
+}
+
+func createOidcTestAccount(t *testing.T, db *gorm.DB, email string, verified bool) model.Account {
+	t.Helper()
+	data := model.Account{Name: "Budi", Email: email, Password: "x", Type: model.AccountTypeCustomer}
+	if verified {
+		now := time.Now()
+		data.EmailVerifiedAt = &now
+	}
+	account, result := repository.NewAccountRepository(db).Create(data)
+	if result.Error != nil {
+		t.Fatalf("create account: %v", result.Error)
+	}
+	return account
+}
+
+func countIdentities(t *testing.T, db *gorm.DB, accountID uint) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(&model.AccountIdentity{}).Where("account_id = ?", accountID).Count(&n).Error; err != nil {
+		t.Fatalf("count identities: %v", err)
+	}
+	return n
+}
+
+func TestOidcAccountLinkedSubject(t *testing.T) {
+	s, db := newOidcTestController(t)
+	account := createOidcTestAccount(t, db, "budi@example.com", false)
+	if _, result := repository.NewAccountIdentityRepository(db).Create(model.AccountIdentity{
+		AccountID: account.ID, Provider: "fake", Subject: "subject-1", Email: "old@example.com",
+	}); result.Error != nil {
+		t.Fatalf("create identity: %v", result.Error)
+	}
+
+	// an earlier link wins even when the email has changed on either side
+	got, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+		Provider: "fake", Subject: "subject-1", Email: "new@example.com",
+	})
+	if err != nil {
+		t.Fatalf("oidc account: %v", err)
+	}
+	if got.ID != account.ID {
+		t.Errorf("account id = %d, want %d", got.ID, account.ID)
+	}
+	if n := countIdentities(t, db, account.ID); n != 1 {
+		t.Errorf("identities = %d, want 1", n)
+	}
+}
+
+func TestOidcAccountLinkedSubjectGone(t *testing.T) {
+	s, db := newOidcTestController(t)
+	if _, result := repository.NewAccountIdentityRepository(db).Create(model.AccountIdentity{
+		AccountID: 42, Provider: "fake", Subject: "subject-1",
+	}); result.Error != nil {
+		t.Fatalf("create identity: %v", result.Error)
+	}
+
+	_, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{Provider: "fake", Subject: "subject-1"})
+	if !errors.Is(err, errOidcAccountGone) {
+		t.Errorf("err = %v, want errOidcAccountGone", err)
+	}
+}
+
+func TestOidcAccountLinkVerifiedEmail(t *testing.T) {
+	s, db := newOidcTestController(t)
+	account := createOidcTestAccount(t, db, "budi@example.com", true)
+
+	got, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+		Provider: "fake", Subject: "subject-1", Email: "budi@example.com", EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("oidc account: %v", err)
+	}
+	if got.ID != account.ID {
+		t.Errorf("account id = %d, want %d", got.ID, account.ID)
+	}
+	link, result := repository.NewAccountIdentityRepository(db).OneByProviderSubject("fake", "subject-1")
+	if result.Error != nil || result.RowsAffected == 0 || link.AccountID != account.ID {
+		t.Errorf("identity not linked: %+v, %v", link, result.Error)
+	}
+}
+
+func TestOidcAccountRefusesUnverifiedLocalEmail(t *testing.T) {
+	s, db := newOidcTestController(t)
+	account := createOidcTestAccount(t, db, "budi@example.com", false)
+
+	_, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+		Provider: "fake", Subject: "subject-1", Email: "budi@example.com", EmailVerified: true,
+	})
+	if !errors.Is(err, errOidcLinkUnverified) {
+		t.Errorf("err = %v, want errOidcLinkUnverified", err)
+	}
+	if n := countIdentities(t, db, account.ID); n != 0 {
+		t.Errorf("identities = %d, want 0", n)
+	}
+}
+
+func TestOidcAccountRefusesUnverifiedProviderEmail(t *testing.T) {
+	s, db := newOidcTestController(t)
+	createOidcTestAccount(t, db, "budi@example.com", true)
+
+	_, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+		Provider: "fake", Subject: "subject-1", Email: "budi@example.com", EmailVerified: false,
+	})
+	if !errors.Is(err, errOidcEmailNotVerified) {
+		t.Errorf("err = %v, want errOidcEmailNotVerified", err)
+	}
+}
+
+func TestOidcAccountCreatesAccount(t *testing.T) {
+	s, db := newOidcTestController(t)
+
+	got, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+		Provider: "fake", Subject: "subject-1", Email: "new@example.com", EmailVerified: true, Name: "New User",
+	})
+	if err != nil {
+		t.Fatalf("oidc account: %v", err)
+	}
+	if got.ID == 0 || got.Email != "new@example.com" || got.Name != "New User" || got.Type != model.AccountTypeCustomer {
+		t.Errorf("unexpected account %+v", got)
+	}
+	if got.EmailVerifiedAt == nil || got.ReferralCode == nil || got.Password == "" {
+		t.Errorf("new account not verified, without referral code or password: %+v", got)
+	}
+	if n := countIdentities(t, db, got.ID); n != 1 {
+		t.Errorf("identities = %d, want 1", n)
+	}
+
+	// the second login finds the same account through the link
+	again, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+		Provider: "fake", Subject: "subject-1", Email: "new@example.com", EmailVerified: true,
+	})
+	if err != nil || again.ID != got.ID {
+		t.Errorf("second login = %d, %v, want %d", again.ID, err, got.ID)
+	}
+}
+
+func TestOidcCallbackRequiresStateCookie(t *testing.T) {
+	s, _ := newOidcTestController(t)
+
+	cases := map[string]string{
+		"no cookie":    "",
+		"other cookie": "state-of-another-login",
+	}
+	for name, cookie := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "provider", Value: "fake"}}
+		c.Request = httptest.NewRequest("GET", "/login/oidc/fake/callback?code=code-1&state=attacker-state", nil)
+		if cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookie})
+		}
+
+		s.GetOidcCallback(c)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, w.Code)
+		}
+	}
+}
+
+func TestOidcAccountCreatesAccountsWithoutPhone(t *testing.T) {
+	s, db := newOidcTestController(t)
+
+	// social login accounts have no phone number, the unique index must not
+	// keep a second one from being created
+	for i, email := range []string{"first@example.com", "second@example.com"} {
+		got, err := s.oidcAccount(newOidcTestContext(), oidc.Identity{
+			Provider: "fake", Subject: email, Email: email, EmailVerified: true,
+		})
+		if err != nil {
+			t.Fatalf("account %d: %v", i+1, err)
+		}
+		if got.PhoneNumber != nil {
+			t.Errorf("account %d phone number = %q, want NULL", i+1, *got.PhoneNumber)
+		}
+	}
+	var n int64
+	if err := db.Model(&model.Account{}).Where("phone_number IS NULL").Count(&n).Error; err != nil {
+		t.Fatalf("count accounts: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("accounts without phone number = %d, want 2", n)
+	}
+}
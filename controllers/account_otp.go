@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/otp"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+)
+
+type PostRequestLoginOtpRequest struct {
+	PhoneNumber string `json:"phone_number"  validate:"required"`
+}
+
+type PostLoginOtpRequest struct {
+	PhoneNumber string `json:"phone_number"  validate:"required"`
+	Code        string `json:"code"  validate:"required,numeric"`
+}
+
+type PostVerifyPhoneRequest struct {
+	Code string `json:"code"  validate:"required,numeric"`
+}
+
+// RequestLoginOtp	goDocs
+// @Summary      request a login otp
+// @Description  send an otp by SMS to the verified phone number of the account, the response never reveals whether the account exists
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostRequestLoginOtpRequest true "Body Request"
+// @Router       /login/otp/request [post]
+func (s *AccountController) PostRequestLoginOtp(c *gin.Context) {
+	// bind data
+	var req PostRequestLoginOtpRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"phone_number": req.PhoneNumber,
+		"api":          "PostRequestLoginOtp",
+	})
+
+	// throttle every number alike, a limit only registered ones hit would
+	// tell them apart
+	if maxIssues, window := s.otp.IssueLimit(); maxIssues > 0 {
+		allowed, err := s.throttle.Hit(c.Request.Context(), "login_otp:"+req.PhoneNumber, maxIssues, window)
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error throttle")
+		} else if !allowed {
+			logCtx.Warn("too many otp requests")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+			return
+		}
+	}
+
+	// from here on every outcome gets the same response
+	response := gin.H{
+		"message": "If the account exists, an otp has been sent.",
+	}
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByVerifiedPhoneNumber(req.PhoneNumber)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Info("otp requested for unknown account")
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if err := s.otp.Issue(c.Request.Context(), otp.PurposeLogin, account.Phone()); err != nil {
+		logCtx.WithField("reason", err).Error("error issue otp")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// LoginOtp	goDocs
+// @Summary      login with phone number and otp
+// @Description  login account with return JWT token
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostLoginOtpRequest true "Body Request"
+// @Router       /login/otp [post]
+func (s *AccountController) PostLoginOtp(c *gin.Context) {
+	// bind data
+	var req PostLoginOtpRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"phone_number": req.PhoneNumber,
+		"api":          "PostLoginOtp",
+	})
+
+	// failures count against the account email, shared with password login,
+	// or against the number when no account signs in with it
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByVerifiedPhoneNumber(req.PhoneNumber)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+		return
+	}
+	lockoutKey := req.PhoneNumber
+	if result.RowsAffected > 0 {
+		lockoutKey = account.Email
+		logCtx = logCtx.WithField("email", account.Email)
+	}
+	if s.loginBlocked(c, logCtx, lockoutKey) {
+		return
+	}
+	if result.RowsAffected == 0 {
+		logCtx.Error("error find account")
+		s.loginFailed(c, logCtx, lockoutKey, nil)
+		return
+	}
+
+	if err := s.otp.Verify(c.Request.Context(), otp.PurposeLogin, req.PhoneNumber, req.Code); err != nil {
+		logCtx.WithField("reason", err).Error("error verify otp")
+		if errors.Is(err, otp.ErrInvalidCode) || errors.Is(err, otp.ErrTooManyAttempts) {
+			s.loginFailed(c, logCtx, lockoutKey, &account)
+			return
+		}
+		s.abortOtp(c, err)
+		return
+	}
+
+	s.loginSucceeded(c, logCtx, account)
+}
+
+// RequestVerifyPhone	goDocs
+// @Summary      request a phone verification otp
+// @Tags         Account
+// @Produce      application/json
+// @Router       /phone/verify/request [post]
+func (s *AccountController) PostRequestVerifyPhone(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PostRequestVerifyPhone",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.PhoneVerifiedAt != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "phone number already verified"})
+		return
+	}
+
+	if err := s.otp.Issue(c.Request.Context(), otp.PurposeVerifyPhone, account.Phone()); err != nil {
+		logCtx.WithField("reason", err).Error("error issue otp")
+		if errors.Is(err, otp.ErrCooldown) || errors.Is(err, otp.ErrTooManyIssues) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error send otp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// VerifyPhone	goDocs
+// @Summary      verify the phone number with an otp
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostVerifyPhoneRequest true "Body Request"
+// @Router       /phone/verify [post]
+func (s *AccountController) PostVerifyPhone(c *gin.Context) {
+	// bind data
+	var req PostVerifyPhoneRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PostVerifyPhone",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	if err := s.otp.Verify(c.Request.Context(), otp.PurposeVerifyPhone, account.Phone(), req.Code); err != nil {
+		logCtx.WithField("reason", err).Error("error verify otp")
+		s.abortOtp(c, err)
+		return
+	}
+
+	now := time.Now()
+	account, result = accountRepo.Update(int(account.ID), model.Account{PhoneVerifiedAt: &now})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"phone_verified_at": account.PhoneVerifiedAt,
+		},
+	})
+}
+
+func (s *AccountController) abortOtp(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, otp.ErrTooManyAttempts):
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, otp.ErrInvalidCode):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error verify otp"})
+	}
+}
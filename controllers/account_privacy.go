@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/jobs"
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/verification"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type PostDeleteMeRequest struct {
+	Password string `json:"password"  validate:"required"`
+	// Required when two factor authentication is enabled
+	Code string `json:"code"`
+}
+
+// ExportMe	goDocs
+// @Summary      export my personal data
+// @Description  build a ZIP archive with the account, chat transcripts, orders and usage in the background and email a download link
+// @Tags         Account
+// @Produce      application/json
+// @Router       /me/export [post]
+func (s *AccountController) PostExportMe(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostExportMe",
+	})
+
+	account, result := repository.NewAccountRepository(s.db.WithContext(c.Request.Context())).OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	key := "data_export:" + strconv.Itoa(int(account.ID))
+	allowed, err := s.throttle.Hit(c.Request.Context(), key, s.dataExport.MaxRequests, s.dataExport.Window)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error throttle")
+	} else if !allowed {
+		logCtx.Warn("too many export requests")
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+		return
+	}
+
+	if err := jobs.Dispatch(jobs.NewDataExportJob(account.ID, int(s.dataExport.LinkTTL/time.Hour))); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch data export")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error export data"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Your data is being prepared, a download link will be sent by email.",
+	})
+}
+
+// DeleteMe	goDocs
+// @Summary      delete my account
+// @Description  soft delete the account and sign out everywhere; the account can be restored by the emailed link until it is purged after the grace period
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostDeleteMeRequest true "Body Request"
+// @Router       /me/delete [post]
+func (s *AccountController) PostDeleteMe(c *gin.Context) {
+	// bind data
+	var req PostDeleteMeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostDeleteMe",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
+		logCtx.WithField("reason", err).Error("error compare password")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+	if account.TotpEnabledAt != nil {
+		ok, err := s.verifySecondFactor(c, account, req.Code, true)
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error verify code")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error delete account"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+	}
+
+	var token string
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		accountRepo := repository.NewAccountRepository(tx)
+		// marks the account for the purge job
+		if _, result := accountRepo.UpdateFields(int(account.ID), map[string]interface{}{"deletion_requested_at": time.Now()}); result.Error != nil {
+			return result.Error
+		}
+		if result := accountRepo.Delete(int(account.ID), false); result.Error != nil {
+			return result.Error
+		}
+		var err error
+		token, err = verification.Issue(tx, account.ID, model.TokenPurposeAccountRestore, account.Email, s.accountDeletion.GracePeriod)
+		return err
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error delete account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error delete account"})
+		return
+	}
+
+	if err := s.revoker.RevokeAll(c.Request.Context(), account.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke sessions")
+	}
+	if _, err := s.refreshTokens.RevokeAccount(account.ID, ""); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke refresh tokens")
+	}
+
+	purgeAt := time.Now().Add(s.accountDeletion.GracePeriod)
+	link := s.accountDeletion.RestoreUrl + url.QueryEscape(token)
+	if err := jobs.Dispatch(jobs.NewAccountDeletedJob(account.Name, account.Email, link, purgeAt.Format("02-01-2006"))); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch account deleted")
+	}
+	logCtx.WithField("account_id", account.ID).Info("account deleted")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"purge_at": purgeAt,
+		},
+	})
+}
+
+// RestoreAccount	goDocs
+// @Summary      restore a deleted account
+// @Description  undo an account deletion with the single-use token sent by email, before the grace period ends
+// @Tags         Account
+// @Produce      application/json
+// @Param        token query string true "restore token"
+// @Router       /account/restore [get]
+func (s *AccountController) GetRestoreAccount(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetRestoreAccount",
+	})
+
+	token := c.Query("token")
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "token is required"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		restoreToken, err := verification.Consume(tx, model.TokenPurposeAccountRestore, token)
+		if err != nil {
+			return err
+		}
+		result := repository.NewAccountRepository(tx).Restore(int(restoreToken.AccountID))
+		if result.Error != nil {
+			return result.Error
+		}
+		// already restored or purged
+		if result.RowsAffected == 0 {
+			return verification.ErrInvalidToken
+		}
+		return nil
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error restore account")
+		if errors.Is(err, verification.ErrInvalidToken) {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error restore account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
@@ -0,0 +1,450 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/controllers/view"
+	"github.com/avarian/primbon-ajaib-backend/jobs"
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/otp"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/verification"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var errContactTaken = errors.New("already used by another account")
+
+type PatchMeRequest struct {
+	Name *string `json:"name"  validate:"omitempty,min=1,max=255"`
+	// An empty address clears it
+	Address *string `json:"address"  validate:"omitempty,max=255"`
+}
+
+type PostChangeEmailRequest struct {
+	Email    string `json:"email"  validate:"required,email"`
+	Password string `json:"password"  validate:"required"`
+	// Required when two factor authentication is enabled
+	Code string `json:"code"`
+}
+
+type PostChangePhoneRequest struct {
+	PhoneNumber string `json:"phone_number"  validate:"required"`
+	Password    string `json:"password"  validate:"required"`
+	// Required when two factor authentication is enabled
+	Code string `json:"code"`
+}
+
+type PostConfirmPhoneChangeRequest struct {
+	PhoneNumber string `json:"phone_number"  validate:"required"`
+	Code        string `json:"code"  validate:"required,numeric"`
+}
+
+// Me	goDocs
+// @Summary      my profile
+// @Tags         Account
+// @Produce      application/json
+// @Router       /me [get]
+func (s *AccountController) GetMe(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "GetMe",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAccount(account),
+	})
+}
+
+// UpdateMe	goDocs
+// @Summary      update my profile
+// @Description  update name and address, email and phone number have their own confirmation flows
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PatchMeRequest true "Body Request"
+// @Router       /me [patch]
+func (s *AccountController) PatchMe(c *gin.Context) {
+	// bind data
+	var req PatchMeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PatchMe",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	fields := map[string]interface{}{}
+	if req.Name != nil {
+		fields["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Address != nil {
+		fields["address"] = strings.TrimSpace(*req.Address)
+	}
+	if len(fields) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Success!",
+			"data":    view.NewAccount(account),
+		})
+		return
+	}
+	account, result = accountRepo.UpdateFields(int(account.ID), fields)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAccount(account),
+	})
+}
+
+// ChangeEmail	goDocs
+// @Summary      request an email change
+// @Description  needs the password, and the two factor code when enabled; send a confirmation link to the new email, the email changes once it is opened
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostChangeEmailRequest true "Body Request"
+// @Router       /me/email [post]
+func (s *AccountController) PostChangeEmail(c *gin.Context) {
+	// bind data
+	var req PostChangeEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"email":    req.Email,
+		"api":      "PostChangeEmail",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	// a stolen access token alone must not be enough to move the account
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
+		logCtx.WithField("reason", err).Error("error compare password")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+	if account.TotpEnabledAt != nil {
+		ok, err := s.verifySecondFactor(c, account, req.Code, true)
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error verify code")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error change email"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+	}
+	if strings.EqualFold(account.Email, req.Email) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "email is unchanged"})
+		return
+	}
+	if _, result := accountRepo.OneByEmail(req.Email); result.Error == nil && result.RowsAffected > 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "email " + errContactTaken.Error()})
+		return
+	}
+
+	token, err := verification.Issue(s.db.WithContext(c.Request.Context()), account.ID, model.TokenPurposeEmailChange, req.Email, s.emailVerification.TokenTTL)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error issue token")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error change email"})
+		return
+	}
+	link := s.emailVerification.ChangeUrl + url.QueryEscape(token)
+	if err := jobs.Dispatch(jobs.NewChangeEmailJob(account.Name, req.Email, link)); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch change email")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error change email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Confirmation sent to the new email.",
+	})
+}
+
+// ConfirmEmailChange	goDocs
+// @Summary      confirm an email change
+// @Description  apply the email change with the token sent to the new email, the previous email is notified
+// @Tags         Account
+// @Produce      application/json
+// @Param        token query string true "Token"
+// @Router       /change-email/confirm [get]
+func (s *AccountController) GetConfirmEmailChange(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetConfirmEmailChange",
+	})
+
+	token := c.Query("token")
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "token is required"})
+		return
+	}
+
+	var previous, account model.Account
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		verificationToken, err := verification.Consume(tx, model.TokenPurposeEmailChange, token)
+		if err != nil {
+			return err
+		}
+
+		accountRepo := repository.NewAccountRepository(tx)
+		var result *gorm.DB
+		previous, result = accountRepo.OneById(int(verificationToken.AccountID))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return verification.ErrInvalidToken
+		}
+
+		// opening the link proves the new address is reachable
+		now := time.Now()
+		account, result = accountRepo.Update(int(previous.ID), model.Account{Email: verificationToken.Target, EmailVerifiedAt: &now})
+		if repository.IsDuplicateEntry(result.Error) {
+			return errContactTaken
+		}
+		return result.Error
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error change email")
+		switch {
+		case errors.Is(err, verification.ErrInvalidToken):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, errContactTaken):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "email " + err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error change email"})
+		}
+		return
+	}
+	logCtx = logCtx.WithFields(log.Fields{"previous_email": previous.Email, "email": account.Email})
+
+	// access tokens carry the email as username, sign the old ones out;
+	// refresh tokens are bound to the account and keep working
+	if err := s.revoker.RevokeAll(c.Request.Context(), previous.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke sessions")
+	}
+	if err := s.guard.Unlock(c.Request.Context(), previous.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error clear login failures")
+	}
+	if err := jobs.Dispatch(jobs.NewContactChangedJob(previous.Name, previous.Email, "", "email", account.Email)); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch contact changed")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAccount(account),
+	})
+}
+
+// ChangePhone	goDocs
+// @Summary      request a phone number change
+// @Description  needs the password, and the two factor code when enabled; send an otp to the new phone number, the number changes once the otp is confirmed
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostChangePhoneRequest true "Body Request"
+// @Router       /me/phone [post]
+func (s *AccountController) PostChangePhone(c *gin.Context) {
+	// bind data
+	var req PostChangePhoneRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username":     c.GetString("username"),
+		"phone_number": req.PhoneNumber,
+		"api":          "PostChangePhone",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	// the phone can reset the password, a stolen access token alone must
+	// not be enough to move it
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
+		logCtx.WithField("reason", err).Error("error compare password")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+	if account.TotpEnabledAt != nil {
+		ok, err := s.verifySecondFactor(c, account, req.Code, true)
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error verify code")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error send otp"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+	}
+	if account.Phone() == req.PhoneNumber {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "phone number is unchanged"})
+		return
+	}
+	if _, result := accountRepo.OneByPhoneNumber(req.PhoneNumber); result.Error == nil && result.RowsAffected > 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "phone number " + errContactTaken.Error()})
+		return
+	}
+
+	if err := s.otp.Issue(c.Request.Context(), changePhonePurpose(account.ID), req.PhoneNumber); err != nil {
+		logCtx.WithField("reason", err).Error("error issue otp")
+		if errors.Is(err, otp.ErrCooldown) || errors.Is(err, otp.ErrTooManyIssues) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error send otp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Otp sent to the new phone number.",
+	})
+}
+
+// ConfirmPhoneChange	goDocs
+// @Summary      confirm a phone number change
+// @Description  apply the phone number change with the otp sent to it, the previous number is notified
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostConfirmPhoneChangeRequest true "Body Request"
+// @Router       /me/phone/confirm [post]
+func (s *AccountController) PostConfirmPhoneChange(c *gin.Context) {
+	// bind data
+	var req PostConfirmPhoneChangeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username":     c.GetString("username"),
+		"phone_number": req.PhoneNumber,
+		"api":          "PostConfirmPhoneChange",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	previous, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	if err := s.otp.Verify(c.Request.Context(), changePhonePurpose(previous.ID), req.PhoneNumber, req.Code); err != nil {
+		logCtx.WithField("reason", err).Error("error verify otp")
+		s.abortOtp(c, err)
+		return
+	}
+
+	now := time.Now()
+	account, result := accountRepo.Update(int(previous.ID), model.Account{PhoneNumber: &req.PhoneNumber, PhoneVerifiedAt: &now})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update account")
+		if repository.IsDuplicateEntry(result.Error) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "phone number " + errContactTaken.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error change phone number"})
+		return
+	}
+
+	if err := jobs.Dispatch(jobs.NewContactChangedJob(previous.Name, "", previous.Phone(), "phone_number", account.Phone())); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch contact changed")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAccount(account),
+	})
+}
+
+// The otp of a phone change is bound to the account that asked for it
+func changePhonePurpose(accountId uint) string {
+	return fmt.Sprintf("%s:%d", otp.PurposeChangePhone, accountId)
+}
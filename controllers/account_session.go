@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/avarian/primbon-ajaib-backend/controllers/view"
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// MySessions	goDocs
+// @Summary      list my active sessions
+// @Description  list the devices signed in to the account, the current one is flagged
+// @Tags         Account
+// @Produce      application/json
+// @Router       /me/sessions [get]
+func (s *AccountController) GetMySessions(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "GetMySessions",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	sessions, err := s.refreshTokens.Sessions(account.ID)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find sessions")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewSessions(sessions, c.GetString("session_id")),
+	})
+}
+
+// RevokeMySession	goDocs
+// @Summary      revoke one of my sessions
+// @Tags         Account
+// @Produce      application/json
+// @Param        id path int true "Session ID"
+// @Router       /me/sessions/{id} [delete]
+func (s *AccountController) DeleteMySession(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "DeleteMySession",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	sessionRepo := repository.NewSessionRepository(s.db.WithContext(c.Request.Context()))
+	session, result := sessionRepo.OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 || session.AccountID != account.ID {
+		logCtx.WithField("reason", result.Error).Error("error find session")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	if err := s.refreshTokens.RevokeFamily(session.FamilyID); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke session")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error revoke session"})
+		return
+	}
+	s.revokeSessions(c, logCtx, []string{session.FamilyID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// RevokeMyOtherSessions	goDocs
+// @Summary      revoke every session but the current one
+// @Tags         Account
+// @Produce      application/json
+// @Router       /me/sessions [delete]
+func (s *AccountController) DeleteMyOtherSessions(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "DeleteMyOtherSessions",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	familyIds, err := s.refreshTokens.RevokeAccount(account.ID, c.GetString("session_id"))
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error revoke sessions")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error revoke sessions"})
+		return
+	}
+	s.revokeSessions(c, logCtx, familyIds)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"revoked": len(familyIds),
+		},
+	})
+}
+
+// Reject the access tokens still held by revoked sessions
+func (s *AccountController) revokeSessions(c *gin.Context, logCtx *log.Entry, familyIds []string) {
+	changes := audit.Changes{}
+	for _, v := range familyIds {
+		if err := s.revoker.RevokeSession(c.Request.Context(), v); err != nil {
+			logCtx.WithFields(log.Fields{"reason": err, "session_id": v}).Error("error revoke session tokens")
+		}
+		changes[v] = audit.Change{From: "active", To: "revoked"}
+	}
+	if len(changes) > 0 {
+		recordAuditEvent(s.db, c, logCtx, newAuditEvent(c, audit.ActionTokenRevoke, model.AuditTargetSession, nil), changes)
+	}
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/session"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type PostRefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"  validate:"required"`
+}
+
+// RefreshToken	goDocs
+// @Summary      refresh an access token
+// @Description  exchange a refresh token for a new access token and refresh token, the old refresh token can not be used again
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostRefreshTokenRequest true "Body Request"
+// @Router       /token/refresh [post]
+func (s *AccountController) PostRefreshToken(c *gin.Context) {
+	// bind data
+	var req PostRefreshTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PostRefreshToken",
+	})
+
+	refreshToken, token, err := s.refreshTokens.Rotate(c.Request.Context(), req.RefreshToken, deviceOf(c))
+	if err != nil {
+		logCtx.WithFields(log.Fields{"reason": err, "family_id": token.FamilyID}).Error("error rotate refresh token")
+		if errors.Is(err, session.ErrRefreshTokenReused) || errors.Is(err, session.ErrInvalidRefreshToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error refresh token"})
+		return
+	}
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneById(int(token.AccountID))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+
+	sessionRepo := repository.NewSessionRepository(s.db.WithContext(c.Request.Context()))
+	loginSession, result := sessionRepo.OneByFamilyID(token.FamilyID)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find session")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+
+	s.respondTokens(c, logCtx, account, loginSession, refreshToken)
+}
+
+// Logout	goDocs
+// @Summary      logout
+// @Description  revoke the current access token and its refresh tokens
+// @Tags         Account
+// @Produce      application/json
+// @Router       /logout [post]
+func (s *AccountController) PostLogout(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostLogout",
+	})
+
+	if err := s.revoker.RevokeToken(c.Request.Context(), c.GetString("jti"), c.GetInt64("expires_at")); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke access token")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error logout"})
+		return
+	}
+	if sessionId := c.GetString("session_id"); sessionId != "" {
+		if err := s.refreshTokens.RevokeFamily(sessionId); err != nil {
+			logCtx.WithField("reason", err).Error("error revoke refresh tokens")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error logout"})
+			return
+		}
+		s.revokeSessions(c, logCtx, []string{sessionId})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// JWKS	goDocs
+// @Summary      public signing keys
+// @Description  JSON Web Key Set of the asymmetric keys that verify access tokens
+// @Tags         Account
+// @Produce      application/json
+// @Router       /.well-known/jwks.json [get]
+func (s *AccountController) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, s.keys.JWKS())
+}
+
+// Apply the email verification login policy, then either start a session
+// or ask for the second factor when the account has one
+func (s *AccountController) respondLogin(c *gin.Context, logCtx *log.Entry, account model.Account) {
+	if s.refuseSuspended(c, logCtx, account) {
+		return
+	}
+	if account.EmailVerifiedAt == nil && s.emailVerification.LoginPolicy == LoginPolicyRefuse {
+		logCtx.Warn("email not verified")
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "email not verified"})
+		return
+	}
+
+	if account.TotpEnabledAt != nil {
+		challengeToken, err := s.challenges.Issue(c.Request.Context(), account.ID)
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error issue two factor challenge")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challengeToken,
+			"expires_in":          int(s.challenges.TTL().Seconds()),
+		})
+		return
+	}
+
+	s.startSession(c, logCtx, account, false)
+}
+
+func (s *AccountController) startSession(c *gin.Context, logCtx *log.Entry, account model.Account, twoFactor bool) {
+	if s.refuseSuspended(c, logCtx, account) {
+		return
+	}
+	refreshToken, familyId, err := s.refreshTokens.Issue(account.ID, deviceOf(c), twoFactor)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error issue refresh token")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+		return
+	}
+	s.recordLogin(c, logCtx, audit.ActionLoginSuccess, account.Email, &account)
+
+	s.respondTokens(c, logCtx, account, model.Session{FamilyID: familyId, TwoFactor: twoFactor}, refreshToken)
+}
+
+// Audit a login attempt. Nobody is signed in yet, so the email tried is
+// the actor; account is nil when no account has that email.
+func (s *AccountController) recordLogin(c *gin.Context, logCtx *log.Entry, action string, email string, account *model.Account) {
+	event := newAuditEvent(c, action, "", nil)
+	event.Actor = email
+	if account != nil {
+		event.TargetType, event.TargetID = model.AuditTargetAccount, &account.ID
+	}
+	recordAuditEvent(s.db, c, logCtx, event, nil)
+}
+
+// Respond with a short lived access token bound to the session
+func (s *AccountController) respondTokens(c *gin.Context, logCtx *log.Entry, account model.Account, loginSession model.Session, refreshToken string) {
+	if s.refuseSuspended(c, logCtx, account) {
+		return
+	}
+	// roles are read again on every refresh, role changes revoke the
+	// current access tokens so they apply right away
+	roles, err := rbac.RolesOf(s.db.WithContext(c.Request.Context()), account.ID)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find roles")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+		return
+	}
+	now := time.Now()
+	claims := &JWTClaim{
+		Email:         account.Email,
+		Username:      account.Email,
+		Type:          account.Type,
+		EmailVerified: account.EmailVerifiedAt != nil,
+		SessionID:     loginSession.FamilyID,
+		TwoFactor:     loginSession.TwoFactor,
+		Roles:         roles,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: now.Add(s.accessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error generate jwt")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+		"expires_in":    int(s.accessTokenTTL.Seconds()),
+	})
+}
+
+// Suspended accounts can not log in nor refresh their tokens
+func (s *AccountController) refuseSuspended(c *gin.Context, logCtx *log.Entry, account model.Account) bool {
+	if account.SuspendedAt == nil {
+		return false
+	}
+	logCtx.WithField("account_id", account.ID).Warn("account suspended")
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+	return true
+}
+
+func deviceOf(c *gin.Context) session.Device {
+	return session.Device{
+		UserAgent: c.Request.UserAgent(),
+		IpAddress: c.ClientIP(),
+	}
+}
@@ -0,0 +1,406 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var totpCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+type PostLoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"  validate:"required"`
+	// Authenticator code or one of the recovery codes
+	Code string `json:"code"  validate:"required"`
+}
+
+type PostTotpCodeRequest struct {
+	Code string `json:"code"  validate:"required"`
+}
+
+type PostDisableTotpRequest struct {
+	Password string `json:"password"  validate:"required"`
+	Code     string `json:"code"  validate:"required"`
+}
+
+// LoginTwoFactor	goDocs
+// @Summary      complete a two factor login
+// @Description  exchange the challenge token from /login and an authenticator or recovery code for a JWT token
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostLoginTwoFactorRequest true "Body Request"
+// @Router       /login/2fa [post]
+func (s *AccountController) PostLoginTwoFactor(c *gin.Context) {
+	// bind data
+	var req PostLoginTwoFactorRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "PostLoginTwoFactor",
+	})
+
+	accountId, err := s.challenges.Attempt(c.Request.Context(), req.ChallengeToken)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error check challenge")
+		switch {
+		case errors.Is(err, totp.ErrTooManyAttempts):
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, totp.ErrInvalidChallenge):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+		}
+		return
+	}
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneById(int(accountId))
+	if result.Error != nil || result.RowsAffected == 0 || account.TotpEnabledAt == nil {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+	logCtx = logCtx.WithField("email", account.Email)
+	if s.loginBlocked(c, logCtx, account.Email) {
+		return
+	}
+
+	ok, err := s.verifySecondFactor(c, account, req.Code, true)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error verify second factor")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error login"})
+		return
+	}
+	if !ok {
+		logCtx.Warn("invalid second factor")
+		s.loginFailed(c, logCtx, account.Email, &account)
+		return
+	}
+
+	if err := s.challenges.Complete(c.Request.Context(), req.ChallengeToken); err != nil {
+		logCtx.WithField("reason", err).Error("error complete challenge")
+	}
+	if err := s.guard.Succeed(c.Request.Context(), account.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error reset login failures")
+	}
+	s.startSession(c, logCtx, account, true)
+}
+
+// EnrollTotp	goDocs
+// @Summary      start two factor enrolment
+// @Description  generate an authenticator secret, returned with its otpauth provisioning uri for a QR code
+// @Tags         Account
+// @Produce      application/json
+// @Router       /me/2fa/enroll [post]
+func (s *AccountController) PostEnrollTotp(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostEnrollTotp",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.TotpEnabledAt != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "two factor authentication already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error generate secret")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error enroll"})
+		return
+	}
+	// the secret only takes effect once confirmed with a code
+	if _, result = accountRepo.Update(int(account.ID), model.Account{TotpSecret: secret}); result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error enroll"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"secret":           secret,
+			"provisioning_uri": totp.ProvisioningURI(s.twoFactor.Issuer, account.Email, secret),
+		},
+	})
+}
+
+// ConfirmTotp	goDocs
+// @Summary      confirm two factor enrolment
+// @Description  enable two factor authentication with a first authenticator code, the recovery codes are only shown in this response
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostTotpCodeRequest true "Body Request"
+// @Router       /me/2fa/confirm [post]
+func (s *AccountController) PostConfirmTotp(c *gin.Context) {
+	// bind data
+	var req PostTotpCodeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostConfirmTotp",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.TotpEnabledAt != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "two factor authentication already enabled"})
+		return
+	}
+	if account.TotpSecret == "" {
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "two factor enrolment not started"})
+		return
+	}
+
+	ok, err := s.verifySecondFactor(c, account, req.Code, false)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error verify code")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error confirm"})
+		return
+	}
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid code"})
+		return
+	}
+
+	var codes []string
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if codes, err = s.replaceRecoveryCodes(tx, account.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		_, result := repository.NewAccountRepository(tx).Update(int(account.ID), model.Account{TotpEnabledAt: &now})
+		return result.Error
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error enable two factor")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error confirm"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTotp	goDocs
+// @Summary      disable two factor authentication
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostDisableTotpRequest true "Body Request"
+// @Router       /me/2fa/disable [post]
+func (s *AccountController) PostDisableTotp(c *gin.Context) {
+	// bind data
+	var req PostDisableTotpRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostDisableTotp",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.TotpEnabledAt == nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "two factor authentication not enabled"})
+		return
+	}
+	if len(c.GetStringSlice("roles")) > 0 && s.twoFactor.ForceAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two factor authentication is required for staff accounts"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
+		logCtx.WithField("reason", err).Error("error compare password")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, nil)
+		return
+	}
+	ok, err := s.verifySecondFactor(c, account, req.Code, true)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error verify code")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error disable"})
+		return
+	}
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewRecoveryCodeRepository(tx).ReplaceByAccountID(int(account.ID), nil); result.Error != nil {
+			return result.Error
+		}
+		// zero values are skipped by Update, clear the columns explicitly
+		return tx.Model(&account).Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil}).Error
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error disable two factor")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error disable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// RegenerateRecoveryCodes	goDocs
+// @Summary      regenerate two factor recovery codes
+// @Description  replace every recovery code, the new codes are only shown in this response
+// @Tags         Account
+// @Produce      application/json
+// @Param        tags body PostTotpCodeRequest true "Body Request"
+// @Router       /me/2fa/recovery-codes [post]
+func (s *AccountController) PostRegenerateRecoveryCodes(c *gin.Context) {
+	// bind data
+	var req PostTotpCodeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostRegenerateRecoveryCodes",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(c.GetString("username"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.TotpEnabledAt == nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "two factor authentication not enabled"})
+		return
+	}
+
+	ok, err := s.verifySecondFactor(c, account, req.Code, false)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error verify code")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error regenerate"})
+		return
+	}
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes, err := s.replaceRecoveryCodes(s.db.WithContext(c.Request.Context()), account.ID)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error regenerate recovery codes")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error regenerate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// Check an authenticator code, or a recovery code when allowed; a matching
+// recovery code is spent
+func (s *AccountController) verifySecondFactor(c *gin.Context, account model.Account, code string, allowRecovery bool) (bool, error) {
+	if totpCodePattern.MatchString(code) {
+		return s.totp.Verify(c.Request.Context(), account.ID, account.TotpSecret, code)
+	}
+	if !allowRecovery {
+		return false, nil
+	}
+	result := repository.NewRecoveryCodeRepository(s.db.WithContext(c.Request.Context())).Use(int(account.ID), totp.HashRecoveryCode(code))
+	return result.RowsAffected == 1, result.Error
+}
+
+func (s *AccountController) replaceRecoveryCodes(db *gorm.DB, accountId uint) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(s.twoFactor.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, v := range codes {
+		hashes = append(hashes, totp.HashRecoveryCode(v))
+	}
+	return codes, repository.NewRecoveryCodeRepository(db).ReplaceByAccountID(int(accountId), hashes).Error
+}
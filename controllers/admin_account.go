@@ -0,0 +1,656 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/controllers/view"
+	"github.com/avarian/primbon-ajaib-backend/jobs"
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/session"
+	"github.com/avarian/primbon-ajaib-backend/service/verification"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var errAdminSelf = errors.New("admins can not apply this action to their own account")
+
+type PatchAdminAccountRequest struct {
+	// Refused, the type grants nothing since roles replaced it
+	Type       *string `json:"type"`
+	ValidUntil *string `json:"valid_until" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type PutAccountRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,dive,required"`
+}
+
+type PostSuspendAccountRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+type AdminAccountController struct {
+	db            *gorm.DB
+	validator     *util.Validator
+	revoker       *session.Revoker
+	refreshTokens *session.RefreshTokens
+	passwordReset PasswordResetConfig
+}
+
+func NewAdminAccountController(db *gorm.DB, validator *util.Validator, revoker *session.Revoker,
+	refreshTokens *session.RefreshTokens, passwordReset PasswordResetConfig) *AdminAccountController {
+	return &AdminAccountController{
+		db:            db,
+		validator:     validator,
+		revoker:       revoker,
+		refreshTokens: refreshTokens,
+		passwordReset: passwordReset,
+	}
+}
+
+// AdminAccountRoles	goDocs
+// @Summary      replace the staff roles of an account
+// @Description  an empty list turns the account back into a customer; current access tokens are revoked so the next refresh carries the new roles
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Param        tags body PutAccountRolesRequest true "Body Request"
+// @Router       /admin/accounts/{id}/roles [put]
+func (s *AdminAccountController) PutAccountRoles(c *gin.Context) {
+	// bind data
+	var req PutAccountRolesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PutAccountRoles",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+	if account.Email == c.GetString("username") {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": errAdminSelf.Error()})
+		return
+	}
+
+	roles := make([]model.Role, 0)
+	if len(req.Roles) > 0 {
+		var result *gorm.DB
+		roles, result = repository.NewRoleRepository(s.db.WithContext(c.Request.Context())).AllByCodes(req.Roles)
+		if result.Error != nil {
+			logCtx.WithField("reason", result.Error).Error("error find role")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find role"})
+			return
+		}
+	}
+	roleIds := make([]uint, 0, len(roles))
+	for _, v := range roles {
+		roleIds = append(roleIds, v.ID)
+	}
+	if len(roles) != len(uniqueStrings(req.Roles)) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "unknown role"})
+		return
+	}
+
+	current, err := rbac.RolesOf(s.db.WithContext(c.Request.Context()), account.ID)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find roles")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find role"})
+		return
+	}
+
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewAccountRoleRepository(tx).ReplaceByAccountID(int(account.ID), roleIds); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountRoles, account), audit.Changes{
+			"roles": {From: current, To: uniqueStrings(req.Roles)},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error update roles")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update roles"})
+		return
+	}
+	if err := s.revoker.RevokeAll(c.Request.Context(), account.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke sessions")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    uniqueStrings(req.Roles),
+	})
+}
+
+// AdminAccountChatboxes	goDocs
+// @Summary      list the chatboxes of an account
+// @Description  for support tickets, every read is written to the audit log
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Router       /admin/accounts/{id}/chatboxes [get]
+func (s *AdminAccountController) GetAccountChatboxes(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "GetAccountChatboxes",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+
+	chatboxes, result := repository.NewChatboxRepository(s.db.WithContext(c.Request.Context())).AllByAccountID(int(account.ID))
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find chatbox")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find chatbox"})
+		return
+	}
+	if err := audit.Record(s.db.WithContext(c.Request.Context()), auditEvent(c, audit.ActionAccountChatsRead, account), nil); err != nil {
+		logCtx.WithField("reason", err).Error("error record audit event")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find chatbox"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewChatboxes(chatboxes),
+	})
+}
+
+// AdminAccountChatboxMessages	goDocs
+// @Summary      read a chatbox of an account
+// @Description  for support tickets, every read is written to the audit log
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Param        code path string true "chatbox code"
+// @Router       /admin/accounts/{id}/chatboxes/{code} [get]
+func (s *AdminAccountController) GetAccountChatboxMessages(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "GetAccountChatboxMessages",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+
+	chatbox, result := repository.NewChatboxRepository(s.db.WithContext(c.Request.Context())).OneByCodeAndAccountID(c.Param("code"), int(account.ID))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find chatbox")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "error find chatbox"})
+		return
+	}
+	messages, result := repository.NewChatboxMessageRepository(s.db.WithContext(c.Request.Context())).AllByChatboxCode(chatbox.Code)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find chatbox message")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find chatbox message"})
+		return
+	}
+	if err := audit.Record(s.db.WithContext(c.Request.Context()), auditEvent(c, audit.ActionAccountChatsRead, account), audit.Changes{
+		"chatbox": {From: nil, To: chatbox.Code},
+	}); err != nil {
+		logCtx.WithField("reason", err).Error("error record audit event")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find chatbox message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewChatboxMessages(messages),
+	})
+}
+
+// AdminAccounts	goDocs
+// @Summary      list accounts
+// @Description  filter by type, premium (true/false), suspended (true/false), deleted=true, created_from/created_to (YYYY-MM-DD) and email search
+// @Tags         Admin
+// @Produce      application/json
+// @Param        type query string false "account type"
+// @Param        premium query string false "true or false"
+// @Param        email query string false "part of the email"
+// @Router       /admin/accounts [get]
+func (s *AdminAccountController) GetAccounts(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetAccounts",
+	})
+
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	accounts, result := accountRepo.Index(c.Request)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAdminAccounts(accounts),
+		"meta":    accountRepo.MetaPaginate(c.Request),
+	})
+}
+
+// AdminAccount	goDocs
+// @Summary      account detail, deleted accounts included
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Router       /admin/accounts/{id} [get]
+func (s *AdminAccountController) GetAccount(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetAccount",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	account, result := repository.NewAccountRepository(s.db.WithContext(c.Request.Context())).OneByIdWithDeleted(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAdminAccount(account),
+	})
+}
+
+// AdminUpdateAccount	goDocs
+// @Summary      update the premium validity
+// @Description  the type can not be changed, staff access is granted with PUT /admin/accounts/{id}/roles
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Param        tags body PatchAdminAccountRequest true "Body Request"
+// @Router       /admin/accounts/{id} [patch]
+func (s *AdminAccountController) PatchAccount(c *gin.Context) {
+	// bind data
+	var req PatchAdminAccountRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	if req.Type != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "type can not be changed, grant roles through PUT /admin/accounts/{id}/roles"})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PatchAccount",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	changes := audit.Changes{}
+	var data model.Account
+	if req.ValidUntil != nil {
+		validUntil, _ := time.ParseInLocation("2006-01-02", *req.ValidUntil, time.Local)
+		if !time.Time(account.ValidUntil).Equal(validUntil) {
+			changes["valid_until"] = audit.Change{From: account.ValidUntil, To: *req.ValidUntil}
+			data.ValidUntil = datatypes.Date(validUntil)
+		}
+	}
+	if len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Success!",
+			"data":    view.NewAdminAccount(account),
+		})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		account, result = repository.NewAccountRepository(tx).Update(id, data)
+		if result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountUpdate, account), changes)
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error update account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    view.NewAdminAccount(account),
+	})
+}
+
+// AdminSuspendAccount	goDocs
+// @Summary      suspend an account
+// @Description  sign the account out everywhere and refuse its logins until unsuspended
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Param        tags body PostSuspendAccountRequest true "Body Request"
+// @Router       /admin/accounts/{id}/suspend [post]
+func (s *AdminAccountController) PostSuspendAccount(c *gin.Context) {
+	// bind data
+	var req PostSuspendAccountRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostSuspendAccount",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+	if account.Email == c.GetString("username") {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": errAdminSelf.Error()})
+		return
+	}
+	if account.SuspendedAt != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "account already suspended"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewAccountRepository(tx).Suspend(int(account.ID), req.Reason); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountSuspend, account), audit.Changes{
+			"suspended_reason": {From: account.SuspendedReason, To: req.Reason},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error suspend account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error suspend account"})
+		return
+	}
+	s.signOut(c, logCtx, account)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// AdminUnsuspendAccount	goDocs
+// @Summary      lift an account suspension
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Router       /admin/accounts/{id}/unsuspend [post]
+func (s *AdminAccountController) PostUnsuspendAccount(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostUnsuspendAccount",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+	if account.SuspendedAt == nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "account not suspended"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewAccountRepository(tx).Unsuspend(int(account.ID)); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountUnsuspend, account), audit.Changes{
+			"suspended_reason": {From: account.SuspendedReason, To: ""},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error unsuspend account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error unsuspend account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// AdminDeleteAccount	goDocs
+// @Summary      soft delete an account
+// @Description  the account is kept until restored, only accounts deleted by their owner are purged
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Router       /admin/accounts/{id} [delete]
+func (s *AdminAccountController) DeleteAccount(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "DeleteAccount",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+	if account.Email == c.GetString("username") {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": errAdminSelf.Error()})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewAccountRepository(tx).Delete(int(account.ID), false); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountDelete, account), nil)
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error delete account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error delete account"})
+		return
+	}
+	s.signOut(c, logCtx, account)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// AdminRestoreAccount	goDocs
+// @Summary      restore a soft deleted account
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Router       /admin/accounts/{id}/restore [post]
+func (s *AdminAccountController) PostRestoreAccount(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostRestoreAccount",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	account, result := repository.NewAccountRepository(s.db.WithContext(c.Request.Context())).OneByIdWithDeleted(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if account.DeletedAt == nil || !account.DeletedAt.Valid {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "account not deleted"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewAccountRepository(tx).Restore(id); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountRestore, account), nil)
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error restore account")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error restore account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// AdminResetAccountPassword	goDocs
+// @Summary      force a password reset
+// @Description  replace the password with an unusable one, sign out everywhere and email a reset link
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "account id"
+// @Router       /admin/accounts/{id}/reset-password [post]
+func (s *AdminAccountController) PostResetAccountPassword(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PostResetAccountPassword",
+	})
+
+	account, ok := s.findAccount(c, logCtx)
+	if !ok {
+		return
+	}
+
+	// nobody knows the random password, the reset link is the only way back in
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		logCtx.WithField("reason", err).Error("error generate password")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error reset password"})
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(random)), 5)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error hash password")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error reset password"})
+		return
+	}
+
+	var token string
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if _, result := repository.NewAccountRepository(tx).Update(int(account.ID), model.Account{
+			Password:          string(hashedPassword),
+			PasswordChangedAt: &now,
+		}); result.Error != nil {
+			return result.Error
+		}
+		var err error
+		token, err = verification.Issue(tx, account.ID, model.TokenPurposePasswordReset, "", s.passwordReset.TokenTTL)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionAccountPasswordReset, account), nil)
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error reset password")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error reset password"})
+		return
+	}
+	s.signOut(c, logCtx, account)
+
+	link := s.passwordReset.ResetUrl + url.QueryEscape(token)
+	if err := jobs.Dispatch(jobs.NewResetPasswordJob(account.Name, account.Email, "", link)); err != nil {
+		logCtx.WithField("reason", err).Error("error dispatch reset password")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+func (s *AdminAccountController) findAccount(c *gin.Context, logCtx *log.Entry) (model.Account, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	account, result := repository.NewAccountRepository(s.db.WithContext(c.Request.Context())).OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return account, false
+	}
+	return account, true
+}
+
+// End every session of the account, access tokens included
+func (s *AdminAccountController) signOut(c *gin.Context, logCtx *log.Entry, account model.Account) {
+	if err := s.revoker.RevokeAll(c.Request.Context(), account.Email); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke sessions")
+	}
+	if _, err := s.refreshTokens.RevokeAccount(account.ID, ""); err != nil {
+		logCtx.WithField("reason", err).Error("error revoke refresh tokens")
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
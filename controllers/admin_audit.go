@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Rows read per query while exporting
+const auditExportBatch = 500
+
+type AuditController struct {
+	db *gorm.DB
+}
+
+func NewAuditController(db *gorm.DB) *AuditController {
+	return &AuditController{
+		db: db,
+	}
+}
+
+// AdminAuditEvents	goDocs
+// @Summary      search the security audit log
+// @Description  filter by actor, action, target_type, target_id, ip_address, request_id, created_from and created_to (YYYY-MM-DD)
+// @Tags         Admin
+// @Produce      application/json
+// @Router       /admin/audit-events [get]
+func (s *AuditController) GetAuditEvents(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetAuditEvents",
+	})
+
+	auditRepo := repository.NewAuditEventRepository(s.db.WithContext(c.Request.Context()))
+	events, result := auditRepo.Index(c.Request)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find audit event")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find audit event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    events,
+		"meta":    auditRepo.MetaPaginate(c.Request),
+	})
+}
+
+// AdminAuditEventsExport	goDocs
+// @Summary      export the security audit log as CSV
+// @Description  takes the same filters as the search, without paging; the export itself is audited
+// @Tags         Admin
+// @Produce      text/csv
+// @Router       /admin/audit-events/export [get]
+func (s *AuditController) GetAuditEventsExport(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "GetAuditEventsExport",
+	})
+
+	filters := audit.Changes{}
+	for key, values := range c.Request.URL.Query() {
+		filters[key] = audit.Change{To: values[0]}
+	}
+	if err := audit.Record(s.db.WithContext(c.Request.Context()), newAuditEvent(c, audit.ActionAuditExport, "", nil), filters); err != nil {
+		logCtx.WithField("reason", err).Error("error record audit event")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error export audit event"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=audit-events-"+time.Now().Format("20060102150405")+".csv")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"id", "created_at", "actor", "action", "target_type", "target_id", "ip_address", "user_agent", "request_id", "changes"})
+	result := repository.NewAuditEventRepository(s.db.WithContext(c.Request.Context())).EachFiltered(c.Request, auditExportBatch, func(events []model.AuditEvent) error {
+		for _, v := range events {
+			targetId, createdAt := "", ""
+			if v.TargetID != nil {
+				targetId = strconv.Itoa(int(*v.TargetID))
+			}
+			if v.CreatedAt != nil {
+				createdAt = v.CreatedAt.Format(time.RFC3339)
+			}
+			if err := w.Write(csvCells(strconv.Itoa(int(v.ID)), createdAt, v.Actor, v.Action, v.TargetType, targetId, v.IpAddress, v.UserAgent, v.RequestID, string(v.Changes))); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	})
+	// the status is sent already, a failure can only cut the file short
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error export audit event")
+	}
+	w.Flush()
+}
+
+// Audit event of an admin acting on an account
+func auditEvent(c *gin.Context, action string, account model.Account) model.AuditEvent {
+	return newAuditEvent(c, action, model.AuditTargetAccount, &account.ID)
+}
+
+func newAuditEvent(c *gin.Context, action string, targetType string, targetId *uint) model.AuditEvent {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	actor := c.GetString("username")
+	// while impersonating the admin is the one acting
+	if impersonator := c.GetString("impersonator"); impersonator != "" {
+		actor = impersonator
+	}
+	return model.AuditEvent{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetId,
+		IpAddress:  c.ClientIP(),
+		UserAgent:  userAgent,
+		RequestID:  c.GetString("request_id"),
+	}
+}
+
+// Record an event that is not part of a transaction. A failure to record
+// is logged and does not fail the request.
+func recordAuditEvent(db *gorm.DB, c *gin.Context, logCtx *log.Entry, event model.AuditEvent, changes audit.Changes) {
+	if err := audit.Record(db.WithContext(c.Request.Context()), event, changes); err != nil {
+		logCtx.WithFields(log.Fields{"reason": err, "action": event.Action}).Error("error record audit event")
+	}
+}
+
+// Actors, user agents and request ids come from clients. Cells a spreadsheet
+// would read as a formula are prefixed with a quote so they stay text.
+func csvCells(cells ...string) []string {
+	for i, v := range cells {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			cells[i] = "'" + v
+		}
+	}
+	return cells
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type PutRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"required,dive,required"`
+}
+
+type RoleController struct {
+	db         *gorm.DB
+	validator  *util.Validator
+	authorizer *rbac.Authorizer
+}
+
+func NewRoleController(db *gorm.DB, validator *util.Validator, authorizer *rbac.Authorizer) *RoleController {
+	return &RoleController{
+		db:         db,
+		validator:  validator,
+		authorizer: authorizer,
+	}
+}
+
+// AdminRoles	goDocs
+// @Summary      list the staff roles with their permissions
+// @Tags         Admin
+// @Produce      application/json
+// @Router       /admin/roles [get]
+func (s *RoleController) GetRoles(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetRoles",
+	})
+
+	roles, result := repository.NewRoleRepository(s.db.WithContext(c.Request.Context())).All(c.Request, "Permissions")
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find role")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    roles,
+		"meta": gin.H{
+			"permissions": rbac.Permissions,
+		},
+	})
+}
+
+// AdminRolePermissions	goDocs
+// @Summary      replace the permissions of a role
+// @Description  applies to every holder of the role on their next request
+// @Tags         Admin
+// @Produce      application/json
+// @Param        code path string true "role code"
+// @Param        tags body PutRolePermissionsRequest true "Body Request"
+// @Router       /admin/roles/{code}/permissions [put]
+func (s *RoleController) PutRolePermissions(c *gin.Context) {
+	// bind data
+	var req PutRolePermissionsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+	permissions := uniqueStrings(req.Permissions)
+	for _, v := range permissions {
+		if !rbac.IsPermission(v) {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "unknown permission " + v})
+			return
+		}
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"role":     c.Param("code"),
+		"api":      "PutRolePermissions",
+	})
+
+	role, result := repository.NewRoleRepository(s.db.WithContext(c.Request.Context())).OneByCode(c.Param("code"), "Permissions")
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find role")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return
+	}
+	// keep someone able to manage roles
+	if role.Code == model.RoleAdmin {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "the ADMIN role can not be changed"})
+		return
+	}
+
+	current := make([]string, 0, len(role.Permissions))
+	for _, v := range role.Permissions {
+		current = append(current, v.Permission)
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewRolePermissionRepository(tx).ReplaceByRoleID(int(role.ID), permissions); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, newAuditEvent(c, audit.ActionRolePermissions, model.AuditTargetRole, &role.ID), audit.Changes{
+			"permissions": {From: current, To: permissions},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error update role permissions")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update role"})
+		return
+	}
+	s.authorizer.Invalidate(c.Request.Context(), role.Code)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    permissions,
+	})
+}
@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/apikey"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type PostApiKeyRequest struct {
+	Partner   string   `json:"partner" validate:"required,max=255"`
+	Scopes    []string `json:"scopes" validate:"required,dive,required"`
+	RateLimit int      `json:"rate_limit" validate:"min=0"`
+	ExpiresAt *string  `json:"expires_at" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type PatchApiKeyRequest struct {
+	Partner   *string  `json:"partner" validate:"omitempty,max=255"`
+	Scopes    []string `json:"scopes" validate:"omitempty,dive,required"`
+	RateLimit *int     `json:"rate_limit" validate:"omitempty,min=0"`
+	ExpiresAt *string  `json:"expires_at" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type ApiKeyController struct {
+	db        *gorm.DB
+	validator *util.Validator
+}
+
+func NewApiKeyController(db *gorm.DB, validator *util.Validator) *ApiKeyController {
+	return &ApiKeyController{
+		db:        db,
+		validator: validator,
+	}
+}
+
+// AdminApiKeys	goDocs
+// @Summary      list partner api keys
+// @Description  filter by partner name
+// @Tags         Admin
+// @Produce      application/json
+// @Router       /admin/api-keys [get]
+func (s *ApiKeyController) GetApiKeys(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetApiKeys",
+	})
+
+	apiKeyRepo := repository.NewApiKeyRepository(s.db.WithContext(c.Request.Context()))
+	keys, result := apiKeyRepo.Index(c.Request)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find api key")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    keys,
+		"meta":    apiKeyRepo.MetaPaginate(c.Request),
+	})
+}
+
+// AdminCreateApiKey	goDocs
+// @Summary      create a partner api key
+// @Description  the key is only shown in this response, send it in the X-Api-Key header
+// @Tags         Admin
+// @Produce      application/json
+// @Param        tags body PostApiKeyRequest true "Body Request"
+// @Router       /admin/api-keys [post]
+func (s *ApiKeyController) PostApiKey(c *gin.Context) {
+	// bind data
+	var req PostApiKeyRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+	scopes, ok := checkScopes(c, req.Scopes)
+	if !ok {
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"partner":  req.Partner,
+		"api":      "PostApiKey",
+	})
+
+	plain, prefix, secretHash, err := apikey.Generate()
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error generate api key")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error create api key"})
+		return
+	}
+	data := model.ApiKey{
+		Partner:    req.Partner,
+		Prefix:     prefix,
+		SecretHash: secretHash,
+		Scopes:     scopes,
+		RateLimit:  req.RateLimit,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt, _ := time.ParseInLocation("2006-01-02", *req.ExpiresAt, time.Local)
+		data.ExpiresAt = &expiresAt
+	}
+
+	var key model.ApiKey
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		if key, result = repository.NewApiKeyRepository(tx).Create(data); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, newAuditEvent(c, audit.ActionApiKeyCreate, model.AuditTargetApiKey, &key.ID), audit.Changes{
+			"partner":    {To: key.Partner},
+			"prefix":     {To: key.Prefix},
+			"scopes":     {To: key.Scopes},
+			"rate_limit": {To: key.RateLimit},
+			"expires_at": {To: key.ExpiresAt},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error create api key")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error create api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    key,
+		"key":     plain,
+	})
+}
+
+// AdminUpdateApiKey	goDocs
+// @Summary      update a partner api key
+// @Description  change the partner name, scopes, rate limit or expiry; the secret stays the same
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "Api Key ID"
+// @Param        tags body PatchApiKeyRequest true "Body Request"
+// @Router       /admin/api-keys/{id} [patch]
+func (s *ApiKeyController) PatchApiKey(c *gin.Context) {
+	// bind data
+	var req PatchApiKeyRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "PatchApiKey",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	key, result := repository.NewApiKeyRepository(s.db.WithContext(c.Request.Context())).OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find api key")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	changes := audit.Changes{}
+	fields := map[string]interface{}{}
+	if req.Partner != nil && *req.Partner != key.Partner {
+		changes["partner"] = audit.Change{From: key.Partner, To: *req.Partner}
+		fields["partner"] = *req.Partner
+	}
+	if req.Scopes != nil {
+		scopes, ok := checkScopes(c, req.Scopes)
+		if !ok {
+			return
+		}
+		if scopes != key.Scopes {
+			changes["scopes"] = audit.Change{From: key.Scopes, To: scopes}
+			fields["scopes"] = scopes
+		}
+	}
+	if req.RateLimit != nil && *req.RateLimit != key.RateLimit {
+		changes["rate_limit"] = audit.Change{From: key.RateLimit, To: *req.RateLimit}
+		fields["rate_limit"] = *req.RateLimit
+	}
+	if req.ExpiresAt != nil {
+		expiresAt, _ := time.ParseInLocation("2006-01-02", *req.ExpiresAt, time.Local)
+		if key.ExpiresAt == nil || !key.ExpiresAt.Equal(expiresAt) {
+			changes["expires_at"] = audit.Change{From: key.ExpiresAt, To: expiresAt}
+			fields["expires_at"] = expiresAt
+		}
+	}
+	if len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Success!",
+			"data":    key,
+		})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		if key, result = repository.NewApiKeyRepository(tx).UpdateFields(id, fields); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, newAuditEvent(c, audit.ActionApiKeyUpdate, model.AuditTargetApiKey, &key.ID), changes)
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error update api key")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    key,
+	})
+}
+
+// AdminRevokeApiKey	goDocs
+// @Summary      revoke a partner api key
+// @Description  the key stops working at once
+// @Tags         Admin
+// @Produce      application/json
+// @Param        id path int true "Api Key ID"
+// @Router       /admin/api-keys/{id} [delete]
+func (s *ApiKeyController) DeleteApiKey(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"username": c.GetString("username"),
+		"api":      "DeleteApiKey",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	key, result := repository.NewApiKeyRepository(s.db.WithContext(c.Request.Context())).OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find api key")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewApiKeyRepository(tx).Delete(id, false); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, newAuditEvent(c, audit.ActionApiKeyRevoke, model.AuditTargetApiKey, &key.ID), audit.Changes{
+			"prefix": {From: key.Prefix},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error revoke api key")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error revoke api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// PartnerMe	goDocs
+// @Summary      who is calling
+// @Description  echo the partner behind the X-Api-Key header, or the signed in user; lets partners check their integration
+// @Tags         Partner
+// @Produce      application/json
+// @Router       /partner/me [get]
+func (s *ApiKeyController) GetPartnerMe(c *gin.Context) {
+	key, ok := partnerOf(c)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Success!",
+			"data": gin.H{
+				"username": c.GetString("username"),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"partner":    key.Partner,
+			"prefix":     key.Prefix,
+			"scopes":     strings.Fields(key.Scopes),
+			"rate_limit": key.RateLimit,
+			"expires_at": key.ExpiresAt,
+		},
+	})
+}
+
+// The api key of a partner request, set by the ApiKey middleware
+func partnerOf(c *gin.Context) (model.ApiKey, bool) {
+	value, ok := c.Get("api_key")
+	if !ok {
+		return model.ApiKey{}, false
+	}
+	key, ok := value.(model.ApiKey)
+	return key, ok
+}
+
+// Validate and join scopes, aborting on an unknown one
+func checkScopes(c *gin.Context, scopes []string) (string, bool) {
+	scopes = uniqueStrings(scopes)
+	for _, v := range scopes {
+		if !apikey.IsScope(v) {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "unknown scope " + v})
+			return "", false
+		}
+	}
+	return strings.Join(scopes, " "), true
+}
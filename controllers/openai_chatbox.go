@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/avarian/primbon-ajaib-backend/controllers/view"
 	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/quota"
 	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/usage"
 	"github.com/avarian/primbon-ajaib-backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -21,17 +25,24 @@ type PostChatboxRequest struct {
 	Message     string `json:"message" validate:"required"`
 }
 
+// Persona recorded on usage records, matching the system prompt below
+const chatboxPersona = "primbon-ajaib"
+
 type OpenaiChatboxController struct {
 	db        *gorm.DB
 	validator *util.Validator
 	apiKey    string
+	quota     *quota.Quota
+	pricing   usage.Pricing
 }
 
-func NewOpenaiChatboxController(db *gorm.DB, validator *util.Validator, apiKey string) *OpenaiChatboxController {
+func NewOpenaiChatboxController(db *gorm.DB, validator *util.Validator, apiKey string, quota *quota.Quota, pricing usage.Pricing) *OpenaiChatboxController {
 	return &OpenaiChatboxController{
 		db:        db,
 		validator: validator,
 		apiKey:    apiKey,
+		quota:     quota,
+		pricing:   pricing,
 	}
 }
 
@@ -57,7 +68,7 @@ func (s *OpenaiChatboxController) PostChatbox(c *gin.Context) {
 	})
 
 	username := c.GetString("username")
-	accountRepo := repository.NewAccountRepository(s.db)
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
 	account, result := accountRepo.OneByEmail(username)
 	if result.Error != nil {
 		err := errors.New("error find account")
@@ -71,27 +82,48 @@ func (s *OpenaiChatboxController) PostChatbox(c *gin.Context) {
 		account.ID = 1
 	}
 
-	chatboxRepo := repository.NewChatboxRepository(s.db)
+	limits, err := s.quota.LimitsFor(account)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find quota")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find quota"})
+		return
+	}
+
+	chatboxRepo := repository.NewChatboxRepository(s.db.WithContext(c.Request.Context()))
 	chatbox, result := chatboxRepo.OneByCodeAndAccountID(req.ChatboxCode, int(account.ID))
 	if result.Error != nil {
 		logCtx.WithField("reason", result.Error).Error("error find chatbox")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find chatbox"})
 		return
-	} else if result.RowsAffected == 0 {
+	}
+	isNewChatbox := result.RowsAffected == 0
+
+	remaining, err := s.quota.ConsumeMessage(c.Request.Context(), account, limits)
+	if err != nil {
+		s.abortQuota(c, logCtx, account, limits, err)
+		return
+	}
+	c.Header("X-Quota-Remaining", quotaHeader(remaining))
+
+	if isNewChatbox {
 		lenMsg := len(req.Message)
 		if len(req.Message) > 250 {
 			lenMsg = 250
 		}
 		code := uuid.New()
-		chatbox, _ = chatboxRepo.Create(model.Chatbox{
+		chatbox, err = s.quota.CreateChatbox(c.Request.Context(), account, limits, model.Chatbox{
 			AccountID: account.ID,
 			Code:      code.String(),
 			Name:      req.Message[:lenMsg],
-			// CreatedBy: username
 		})
+		if err != nil {
+			s.quota.RefundMessage(c.Request.Context(), account)
+			s.abortQuota(c, logCtx, account, limits, err)
+			return
+		}
 	}
 
-	chatboxMessageRepo := repository.NewChatboxMessageRepository(s.db)
+	chatboxMessageRepo := repository.NewChatboxMessageRepository(s.db.WithContext(c.Request.Context()))
 	chatboxMessage, result := chatboxMessageRepo.AllByChatboxCode(chatbox.Code)
 	if result.Error != nil {
 		logCtx.WithField("reason", result.Error).Error("error find chatbox message")
@@ -128,10 +160,25 @@ func (s *OpenaiChatboxController) PostChatbox(c *gin.Context) {
 		logCtx.WithFields(log.Fields{
 			"reason": err.Error(),
 		}).Error("failed get response chatbox")
+		s.quota.RefundMessage(c.Request.Context(), account)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error generate chatbox"})
 		return
 	}
 
+	usageRecordRepo := repository.NewUsageRecordRepository(s.db.WithContext(c.Request.Context()))
+	if _, result := usageRecordRepo.Create(model.UsageRecord{
+		AccountID:        account.ID,
+		ChatboxCode:      chatbox.Code,
+		Model:            base.Model,
+		Persona:          chatboxPersona,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		Cost:             s.pricing.Cost(base.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+	}); result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error create usage record")
+	}
+
 	chatboxMessageRepo.Create(model.ChatboxMessage{
 		ChatboxCode: chatbox.Code,
 		Role:        openai.ChatMessageRoleUser,
@@ -153,13 +200,80 @@ func (s *OpenaiChatboxController) PostChatbox(c *gin.Context) {
 	})
 }
 
+func (s *OpenaiChatboxController) GetQuota(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetQuota",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	limits, err := s.quota.LimitsFor(account)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find quota")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find quota"})
+		return
+	}
+
+	usage, err := s.quota.Usage(c.Request.Context(), account, limits)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error find quota usage")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find quota usage"})
+		return
+	}
+
+	c.Header("X-Quota-Remaining", quotaHeader(usage.MessagesRemaining))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    usage,
+	})
+}
+
+// Respond 429 when the daily message quota is spent (it resets tomorrow) and
+// 402 when the chatbox limit is reached (only an upgrade lifts it).
+func (s *OpenaiChatboxController) abortQuota(c *gin.Context, logCtx *log.Entry, account model.Account, limits quota.Limits, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, quota.ErrMessageQuotaExceeded):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, quota.ErrChatboxQuotaExceeded):
+		status = http.StatusPaymentRequired
+	default:
+		logCtx.WithField("reason", err).Error("error consume quota")
+		c.AbortWithStatusJSON(status, gin.H{"error": "error consume quota"})
+		return
+	}
+
+	logCtx.WithField("reason", err).Warn("quota exceeded")
+	usage, _ := s.quota.Usage(c.Request.Context(), account, limits)
+	c.Header("X-Quota-Remaining", "0")
+	c.AbortWithStatusJSON(status, gin.H{
+		"error": err.Error(),
+		"quota": usage,
+	})
+}
+
+func quotaHeader(remaining int) string {
+	if remaining < 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(remaining)
+}
+
 func (s *OpenaiChatboxController) GetListChatbox(c *gin.Context) {
 	// log
 	logCtx := log.WithFields(log.Fields{
 		"api": "GetListChatbox",
 	})
 	username := c.GetString("username")
-	accountRepo := repository.NewAccountRepository(s.db)
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
 	account, result := accountRepo.OneByEmail(username)
 	if result.Error != nil {
 		err := errors.New("error find account")
@@ -171,7 +285,7 @@ func (s *OpenaiChatboxController) GetListChatbox(c *gin.Context) {
 		return
 	}
 
-	chatboxRepo := repository.NewChatboxRepository(s.db)
+	chatboxRepo := repository.NewChatboxRepository(s.db.WithContext(c.Request.Context()))
 	chatbox, result := chatboxRepo.AllByAccountID(int(account.ID))
 	if result.Error != nil && !errors.Is(gorm.ErrRecordNotFound, result.Error) {
 		logCtx.WithField("reason", result.Error).Error("error find chatbox message")
@@ -181,7 +295,7 @@ func (s *OpenaiChatboxController) GetListChatbox(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Success!",
-		"data":    chatbox,
+		"data":    view.NewChatboxes(chatbox),
 	})
 }
 
@@ -193,7 +307,7 @@ func (s *OpenaiChatboxController) GetChatboxMessages(c *gin.Context) {
 
 	code := c.Param("code")
 	username := c.GetString("username")
-	accountRepo := repository.NewAccountRepository(s.db)
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
 	account, result := accountRepo.OneByEmail(username)
 	if result.Error != nil {
 		err := errors.New("error find account")
@@ -205,7 +319,7 @@ func (s *OpenaiChatboxController) GetChatboxMessages(c *gin.Context) {
 		return
 	}
 
-	chatboxRepo := repository.NewChatboxRepository(s.db)
+	chatboxRepo := repository.NewChatboxRepository(s.db.WithContext(c.Request.Context()))
 	chatbox, result := chatboxRepo.OneByCodeAndAccountID(code, int(account.ID))
 	if result.Error != nil || result.RowsAffected == 0 {
 		logCtx.WithField("reason", result.Error).Error("error find chatbox")
@@ -213,7 +327,7 @@ func (s *OpenaiChatboxController) GetChatboxMessages(c *gin.Context) {
 		return
 	}
 
-	chatboxMessageRepo := repository.NewChatboxMessageRepository(s.db)
+	chatboxMessageRepo := repository.NewChatboxMessageRepository(s.db.WithContext(c.Request.Context()))
 	chatboxMessage, result := chatboxMessageRepo.AllByChatboxCode(chatbox.Code)
 	if result.Error != nil {
 		logCtx.WithField("reason", result.Error).Error("error find chatbox message")
@@ -223,6 +337,6 @@ func (s *OpenaiChatboxController) GetChatboxMessages(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Success!",
-		"data":    chatboxMessage,
+		"data":    view.NewChatboxMessages(chatboxMessage),
 	})
 }
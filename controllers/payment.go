@@ -0,0 +1,483 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/payment"
+	"github.com/avarian/primbon-ajaib-backend/service/referral"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/voucher"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type PostCheckoutRequest struct {
+	PlanCode    string `json:"plan_code" validate:"required"`
+	VoucherCode string `json:"voucher_code"`
+}
+
+type PostPlanRequest struct {
+	Code           string `json:"code" validate:"required"`
+	Name           string `json:"name" validate:"required"`
+	Price          int64  `json:"price" validate:"required,gt=0"`
+	Days           int    `json:"days" validate:"required,gt=0"`
+	IsActive       *bool  `json:"is_active"`
+	MessagesPerDay *int   `json:"messages_per_day" validate:"omitempty,min=0"`
+	MaxChatboxes   *int   `json:"max_chatboxes" validate:"omitempty,min=0"`
+}
+
+type PaymentController struct {
+	db        *gorm.DB
+	validator *util.Validator
+	gateway   payment.Gateway
+	referral  *referral.Program
+}
+
+func NewPaymentController(db *gorm.DB, validator *util.Validator, gateway payment.Gateway, referral *referral.Program) *PaymentController {
+	return &PaymentController{
+		db:        db,
+		validator: validator,
+		gateway:   gateway,
+		referral:  referral,
+	}
+}
+
+// ListPlan	goDocs
+// @Summary      list premium plans
+// @Description  list active premium plans available for checkout
+// @Tags         Payment
+// @Produce      application/json
+// @Router       /plans [get]
+func (s *PaymentController) GetPlans(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetPlans",
+	})
+
+	planRepo := repository.NewPlanRepository(s.db.WithContext(c.Request.Context()))
+	plans, result := planRepo.AllActive()
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find plan")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    plans,
+	})
+}
+
+// Checkout	goDocs
+// @Summary      checkout a premium plan
+// @Description  create a pending order and return the gateway payment url
+// @Tags         Payment
+// @Produce      application/json
+// @Param        tags body PostCheckoutRequest true "Body Request"
+// @Router       /payment/checkout [post]
+func (s *PaymentController) PostCheckout(c *gin.Context) {
+	// bind data
+	var req PostCheckoutRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"plan": req.PlanCode,
+		"api":  "PostCheckout",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		err := errors.New("error find account")
+		if result.Error != nil {
+			err = result.Error
+		}
+		logCtx.WithField("reason", err).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	planRepo := repository.NewPlanRepository(s.db.WithContext(c.Request.Context()))
+	plan, result := planRepo.OneByCode(req.PlanCode)
+	if result.Error != nil || result.RowsAffected == 0 || !plan.IsActive {
+		logCtx.WithField("reason", result.Error).Error("error find plan")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		return
+	}
+
+	order := model.PaymentOrder{
+		Code:      uuid.New().String(),
+		AccountID: account.ID,
+		PlanCode:  plan.Code,
+		Amount:    plan.Price,
+		Days:      plan.Days,
+		Status:    model.PaymentOrderStatusPending,
+		Provider:  s.gateway.Name(),
+	}
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if req.VoucherCode != "" {
+			v, err := voucher.Redeem(tx, req.VoucherCode, account, model.VoucherTypeDiscount, plan.Code, order.Code)
+			if err != nil {
+				return err
+			}
+			order.Amount = voucher.DiscountedAmount(v, plan.Price)
+		}
+
+		var result *gorm.DB
+		order, result = repository.NewPaymentOrderRepository(tx).Create(order)
+		return result.Error
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error create order")
+		if status, ok := voucherErrorStatus(err); ok {
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error create order"})
+		return
+	}
+
+	orderRepo := repository.NewPaymentOrderRepository(s.db.WithContext(c.Request.Context()))
+	trx, err := s.gateway.CreateTransaction(order, account)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error create transaction")
+		s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+			repository.NewPaymentOrderRepository(tx).Transition(order.Code, model.PaymentOrderStatusExpired, model.PaymentOrderStatusPending)
+			return voucher.Release(tx, order.Code)
+		})
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "error create transaction"})
+		return
+	}
+
+	order, result = orderRepo.Update(int(order.ID), model.PaymentOrder{
+		ProviderRef: trx.Reference,
+		PaymentUrl:  trx.PaymentUrl,
+	})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update order")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    order,
+	})
+}
+
+func (s *PaymentController) GetOrders(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetOrders",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	orderRepo := repository.NewPaymentOrderRepository(s.db.WithContext(c.Request.Context()))
+	orders, result := orderRepo.AllByAccountID(int(account.ID))
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find order")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    orders,
+	})
+}
+
+func (s *PaymentController) GetOrder(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetOrder",
+	})
+
+	code := c.Param("code")
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	orderRepo := repository.NewPaymentOrderRepository(s.db.WithContext(c.Request.Context()))
+	order, result := orderRepo.OneByCodeAndAccountID(code, int(account.ID))
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find order")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    order,
+	})
+}
+
+// PaymentNotification	goDocs
+// @Summary      payment gateway webhook
+// @Description  verify the notification signature and move the order state
+// @Tags         Payment
+// @Produce      application/json
+// @Router       /payment/notification [post]
+func (s *PaymentController) PostNotification(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"provider": s.gateway.Name(),
+		"api":      "PostNotification",
+	})
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error read body")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notification, err := s.gateway.ParseNotification(body)
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error parse notification")
+		status := http.StatusBadRequest
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			status = http.StatusUnauthorized
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	logCtx = logCtx.WithFields(log.Fields{
+		"order":  notification.OrderCode,
+		"status": notification.Status,
+	})
+
+	orderRepo := repository.NewPaymentOrderRepository(s.db.WithContext(c.Request.Context()))
+	order, result := orderRepo.OneByCode(notification.OrderCode)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find order")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+	if notification.GrossAmount != order.Amount {
+		logCtx.WithField("amount", notification.GrossAmount).Error("amount mismatch")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "amount mismatch"})
+		return
+	}
+
+	applied := false
+	err = s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var from []string
+		days := 0
+		switch notification.Status {
+		case model.PaymentOrderStatusPaid:
+			from, days = []string{model.PaymentOrderStatusPending}, order.Days
+		case model.PaymentOrderStatusExpired:
+			from = []string{model.PaymentOrderStatusPending}
+		case model.PaymentOrderStatusRefunded:
+			from, days = []string{model.PaymentOrderStatusPaid}, -order.Days
+		default:
+			return nil
+		}
+
+		result := repository.NewPaymentOrderRepository(tx).Transition(order.Code, notification.Status, from...)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		applied = true
+
+		if notification.Status == model.PaymentOrderStatusExpired {
+			return voucher.Release(tx, order.Code)
+		}
+		if days != 0 {
+			accountRepo := repository.NewAccountRepository(tx)
+			before, result := accountRepo.OneById(int(order.AccountID))
+			if result.Error != nil {
+				return result.Error
+			}
+			account, result := accountRepo.ExtendValidUntil(int(order.AccountID), days)
+			if result.Error != nil {
+				return result.Error
+			}
+			action := audit.ActionPremiumRevoke
+			if days > 0 {
+				action = audit.ActionPremiumGrant
+				account, result = accountRepo.Update(int(order.AccountID), model.Account{PlanCode: order.PlanCode})
+				if result.Error != nil {
+					return result.Error
+				}
+				rewarded, ok, err := s.referral.Reward(tx, int(order.AccountID))
+				if err != nil {
+					return err
+				}
+				if ok {
+					if err := audit.Record(tx, auditEvent(c, audit.ActionPremiumGrant, rewarded), audit.Changes{
+						"referral_reward": {To: order.Code},
+					}); err != nil {
+						return err
+					}
+				}
+			}
+			return audit.Record(tx, auditEvent(c, action, account), audit.Changes{
+				"valid_until": {From: before.ValidUntil, To: account.ValidUntil},
+				"order":       {To: order.Code},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error apply notification")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error apply notification"})
+		return
+	}
+
+	logCtx.WithField("applied", applied).Info("notification processed")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+func (s *PaymentController) PostPlan(c *gin.Context) {
+	// bind data
+	var req PostPlanRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"plan": req.Code,
+		"api":  "PostPlan",
+	})
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	messagesPerDay, maxChatboxes := 0, 0
+	if req.MessagesPerDay != nil {
+		messagesPerDay = *req.MessagesPerDay
+	}
+	if req.MaxChatboxes != nil {
+		maxChatboxes = *req.MaxChatboxes
+	}
+
+	planRepo := repository.NewPlanRepository(s.db.WithContext(c.Request.Context()))
+	plan, result := planRepo.Create(model.Plan{
+		Code:           req.Code,
+		Name:           req.Name,
+		Price:          req.Price,
+		Days:           req.Days,
+		IsActive:       isActive,
+		MessagesPerDay: messagesPerDay,
+		MaxChatboxes:   maxChatboxes,
+	})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error create plan")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    plan,
+	})
+}
+
+func (s *PaymentController) PutPlan(c *gin.Context) {
+	// bind data
+	var req PostPlanRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"plan": req.Code,
+		"api":  "PutPlan",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	planRepo := repository.NewPlanRepository(s.db.WithContext(c.Request.Context()))
+	plan, result := planRepo.Update(id, model.Plan{
+		Code:  req.Code,
+		Name:  req.Name,
+		Price: req.Price,
+		Days:  req.Days,
+	})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update plan")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+		return
+	}
+	// AssignData skips zero values, so flags and limits are written explicitly
+	values := map[string]interface{}{}
+	if req.IsActive != nil {
+		values["is_active"] = *req.IsActive
+	}
+	if req.MessagesPerDay != nil {
+		values["messages_per_day"] = *req.MessagesPerDay
+	}
+	if req.MaxChatboxes != nil {
+		values["max_chatboxes"] = *req.MaxChatboxes
+	}
+	if len(values) > 0 {
+		if result := s.db.WithContext(c.Request.Context()).Model(&plan).Updates(values); result.Error != nil {
+			logCtx.WithField("reason", result.Error).Error("error update plan")
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    plan,
+	})
+}
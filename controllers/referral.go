@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/referral"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReferralController struct {
+	db        *gorm.DB
+	validator *util.Validator
+}
+
+func NewReferralController(db *gorm.DB, validator *util.Validator) *ReferralController {
+	return &ReferralController{
+		db:        db,
+		validator: validator,
+	}
+}
+
+// MyReferral	goDocs
+// @Summary      referral dashboard
+// @Description  referral code of the current account with its referrals and earned days
+// @Tags         Referral
+// @Produce      application/json
+// @Router       /me/referral [get]
+func (s *ReferralController) GetMyReferral(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetMyReferral",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	// accounts registered before the referral program get their code lazily
+	if account.ReferralCode == nil {
+		code, err := referral.GenerateCode()
+		if err != nil {
+			logCtx.WithField("reason", err).Error("error generate referral code")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error generate referral code"})
+			return
+		}
+		account, result = accountRepo.Update(int(account.ID), model.Account{ReferralCode: &code})
+		if result.Error != nil {
+			logCtx.WithField("reason", result.Error).Error("error update account")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error update account"})
+			return
+		}
+	}
+
+	referralRepo := repository.NewReferralRepository(s.db.WithContext(c.Request.Context()))
+	referrals, result := referralRepo.AllByReferrerID(int(account.ID))
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find referral")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find referral"})
+		return
+	}
+
+	counts := map[string]int{
+		model.ReferralStatusPending:  0,
+		model.ReferralStatusRewarded: 0,
+		model.ReferralStatusRejected: 0,
+	}
+	earnedDays := 0
+	items := []gin.H{}
+	for _, v := range referrals {
+		counts[v.Status]++
+		earnedDays += v.RewardDays
+		items = append(items, gin.H{
+			"status":      v.Status,
+			"reward_days": v.RewardDays,
+			"rewarded_at": v.RewardedAt,
+			"created_at":  v.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"referral_code": account.ReferralCode,
+			"counts":        counts,
+			"earned_days":   earnedDays,
+			"referrals":     items,
+		},
+	})
+}
+
+// ReferralReport	goDocs
+// @Summary      referral report
+// @Description  paginated referrals and a summary per referrer and status
+// @Tags         Referral
+// @Produce      application/json
+// @Param        status query string false "PENDING, REWARDED or REJECTED"
+// @Param        referrer_id query int false "limit to one referrer"
+// @Router       /admin/referrals [get]
+func (s *ReferralController) GetReferrals(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetReferrals",
+	})
+
+	referralRepo := repository.NewReferralRepository(s.db.WithContext(c.Request.Context()))
+	referrals, result := referralRepo.Index(c.Request)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find referral")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find referral"})
+		return
+	}
+
+	summary, result := referralRepo.Summary(c.Request, 0)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error summarize referral")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error summarize referral"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    referrals,
+		"summary": summary,
+		"meta":    referralRepo.MetaPaginate(c.Request),
+	})
+}
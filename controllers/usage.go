@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type UsageController struct {
+	db        *gorm.DB
+	validator *util.Validator
+}
+
+func NewUsageController(db *gorm.DB, validator *util.Validator) *UsageController {
+	return &UsageController{
+		db:        db,
+		validator: validator,
+	}
+}
+
+// MyUsage	goDocs
+// @Summary      token usage of the current account
+// @Description  today and this month totals with a daily breakdown
+// @Tags         Usage
+// @Produce      application/json
+// @Router       /me/usage [get]
+func (s *UsageController) GetMyUsage(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetMyUsage",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+	usageRepo := repository.NewUsageRecordRepository(s.db.WithContext(c.Request.Context()))
+	daily, result := usageRepo.Aggregate("daily", "", monthStart, tomorrow, int(account.ID))
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error aggregate usage")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error aggregate usage"})
+		return
+	}
+
+	var today, month repository.UsageAggregate
+	today.Period = now.Format("2006-01-02")
+	month.Period = now.Format("2006-01")
+	for _, v := range daily {
+		if v.Period == today.Period {
+			today = v
+		}
+		month.Requests += v.Requests
+		month.PromptTokens += v.PromptTokens
+		month.CompletionTokens += v.CompletionTokens
+		month.TotalTokens += v.TotalTokens
+	}
+	// cost is for finance, users only see their token consumption
+	today.Cost = 0
+	for i := range daily {
+		daily[i].Cost = 0
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"today":      today,
+			"this_month": month,
+			"daily":      daily,
+		},
+	})
+}
+
+// UsageReport	goDocs
+// @Summary      aggregated token usage and cost
+// @Description  daily or monthly aggregates grouped by account, model or persona
+// @Tags         Usage
+// @Produce      application/json
+// @Param        period query string false "daily or monthly"
+// @Param        group_by query string false "account, model or persona"
+// @Param        from query string false "YYYY-MM-DD"
+// @Param        to query string false "YYYY-MM-DD, exclusive"
+// @Param        account_id query int false "limit to one account"
+// @Router       /admin/usage [get]
+func (s *UsageController) GetUsage(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetUsage",
+	})
+
+	period := c.DefaultQuery("period", "daily")
+	if period != "daily" && period != "monthly" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "period must be daily or monthly"})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", "account")
+	if groupBy != "account" && groupBy != "model" && groupBy != "persona" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "group_by must be account, model or persona"})
+		return
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -30)
+	if period == "monthly" {
+		from = time.Date(now.Year(), now.Month()-11, 1, 0, 0, 0, 0, now.Location())
+	}
+	var err error
+	if q := c.Query("from"); q != "" {
+		if from, err = time.ParseInLocation("2006-01-02", q, now.Location()); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid from date"})
+			return
+		}
+	}
+	if q := c.Query("to"); q != "" {
+		if to, err = time.ParseInLocation("2006-01-02", q, now.Location()); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid to date"})
+			return
+		}
+	}
+	accountId, _ := strconv.Atoi(c.Query("account_id"))
+
+	usageRepo := repository.NewUsageRecordRepository(s.db.WithContext(c.Request.Context()))
+	aggregates, result := usageRepo.Aggregate(period, groupBy, from, to, accountId)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error aggregate usage")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error aggregate usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    aggregates,
+		"meta": gin.H{
+			"period":   period,
+			"group_by": groupBy,
+			"from":     from.Format("2006-01-02"),
+			"to":       to.Format("2006-01-02"),
+		},
+	})
+}
@@ -0,0 +1,116 @@
+// Package view holds the API representations of models. Models double as
+// database tables, so handlers respond with these types instead, which only
+// carry the fields a client may see.
+package view
+
+import (
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/datatypes"
+)
+
+type Account struct {
+	ID               uint           `json:"id"`
+	Name             string         `json:"name"`
+	Email            string         `json:"email"`
+	PhoneNumber      *string        `json:"phone_number"`
+	Address          string         `json:"address"`
+	Type             string         `json:"type"`
+	EmailVerifiedAt  *time.Time     `json:"email_verified_at"`
+	PhoneVerifiedAt  *time.Time     `json:"phone_verified_at"`
+	TwoFactorEnabled bool           `json:"two_factor_enabled"`
+	ValidUntil       datatypes.Date `json:"valid_until"`
+	PlanCode         string         `json:"plan_code"`
+	ReferralCode     *string        `json:"referral_code"`
+	ReminderOptOut   bool           `json:"reminder_opt_out"`
+	CreatedAt        *time.Time     `json:"created_at"`
+}
+
+func NewAccount(account model.Account) Account {
+	return Account{
+		ID:               account.ID,
+		Name:             account.Name,
+		Email:            account.Email,
+		PhoneNumber:      account.PhoneNumber,
+		Address:          account.Address,
+		Type:             account.Type,
+		EmailVerifiedAt:  account.EmailVerifiedAt,
+		PhoneVerifiedAt:  account.PhoneVerifiedAt,
+		TwoFactorEnabled: account.TotpEnabledAt != nil,
+		ValidUntil:       account.ValidUntil,
+		PlanCode:         account.PlanCode,
+		ReferralCode:     account.ReferralCode,
+		ReminderOptOut:   account.ReminderOptOut,
+		CreatedAt:        account.CreatedAt,
+	}
+}
+
+type Session struct {
+	ID         uint       `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IpAddress  string     `json:"ip_address"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+	Current    bool       `json:"current"`
+}
+
+// NewSessions flags the session the request was made from as current.
+func NewSessions(sessions []model.Session, currentFamilyId string) []Session {
+	views := make([]Session, 0, len(sessions))
+	for _, v := range sessions {
+		views = append(views, Session{
+			ID:         v.ID,
+			UserAgent:  v.UserAgent,
+			IpAddress:  v.IpAddress,
+			LastSeenAt: v.LastSeenAt,
+			CreatedAt:  v.CreatedAt,
+			Current:    v.FamilyID == currentFamilyId,
+		})
+	}
+	return views
+}
+
+// AdminAccount adds the moderation and bookkeeping fields admins see.
+type AdminAccount struct {
+	Account
+	ReferredByID      *uint      `json:"referred_by_id"`
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	SuspendedAt       *time.Time `json:"suspended_at"`
+	SuspendedReason   string     `json:"suspended_reason"`
+	// Set when the owner deleted the account
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at"`
+	CreatedBy           string     `json:"created_by"`
+	UpdatedBy           string     `json:"updated_by"`
+	DeletedBy           *string    `json:"deleted_by"`
+	UpdatedAt           *time.Time `json:"updated_at"`
+	DeletedAt           *time.Time `json:"deleted_at"`
+}
+
+func NewAdminAccount(account model.Account) AdminAccount {
+	var deletedAt *time.Time
+	if account.DeletedAt != nil && account.DeletedAt.Valid {
+		deletedAt = &account.DeletedAt.Time
+	}
+	return AdminAccount{
+		Account:             NewAccount(account),
+		ReferredByID:        account.ReferredByID,
+		PasswordChangedAt:   account.PasswordChangedAt,
+		SuspendedAt:         account.SuspendedAt,
+		SuspendedReason:     account.SuspendedReason,
+		DeletionRequestedAt: account.DeletionRequestedAt,
+		CreatedBy:           account.CreatedBy,
+		UpdatedBy:           account.UpdatedBy,
+		DeletedBy:           account.DeletedBy,
+		UpdatedAt:           account.UpdatedAt,
+		DeletedAt:           deletedAt,
+	}
+}
+
+func NewAdminAccounts(accounts []model.Account) []AdminAccount {
+	views := make([]AdminAccount, 0, len(accounts))
+	for _, v := range accounts {
+		views = append(views, NewAdminAccount(v))
+	}
+	return views
+}
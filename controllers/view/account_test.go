@@ -0,0 +1,128 @@
+package view_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/controllers/view"
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func fullAccount() model.Account {
+	now := time.Now()
+	phoneNumber := "08123456789"
+	referralCode := "REF123"
+	referredBy := uint(7)
+	deletedBy := "admin@example.com"
+	return model.Account{
+		ID:                1,
+		Name:              "Budi",
+		Email:             "budi@example.com",
+		PhoneNumber:       &phoneNumber,
+		Password:          "$2a$05$hashedpassword",
+		Address:           "Jakarta",
+		Type:              model.AccountTypeCustomer,
+		PasswordChangedAt: &now,
+		EmailVerifiedAt:   &now,
+		PhoneVerifiedAt:   &now,
+		TotpSecret:        "JBSWY3DPEHPK3PXP",
+		TotpEnabledAt:     &now,
+		ValidUntil:        datatypes.Date(now),
+		PlanCode:          "MONTHLY",
+		ReferralCode:      &referralCode,
+		ReferredByID:      &referredBy,
+		SuspendedAt:       &now,
+		SuspendedReason:   "spam",
+		CreatedBy:         "SYSTEM",
+		UpdatedBy:         "admin@example.com",
+		DeletedBy:         &deletedBy,
+		CreatedAt:         &now,
+		UpdatedAt:         &now,
+		DeletedAt:         &gorm.DeletedAt{Time: now, Valid: true},
+	}
+}
+
+// Marshal v and decode it back into a generic map to look at the keys
+func keysOf(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	keys := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	return keys
+}
+
+func assertNoKeys(t *testing.T, keys map[string]interface{}, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if _, ok := keys[name]; ok {
+			t.Errorf("%q must not be serialized, got %v", name, keys[name])
+		}
+	}
+}
+
+func TestAccountHidesSecrets(t *testing.T) {
+	keys := keysOf(t, view.NewAccount(fullAccount()))
+	assertNoKeys(t, keys, "password", "totp_secret", "created_by", "updated_by", "deleted_by")
+	if keys["two_factor_enabled"] != true {
+		t.Errorf("two_factor_enabled = %v, want true", keys["two_factor_enabled"])
+	}
+}
+
+func TestAdminAccountHidesSecrets(t *testing.T) {
+	keys := keysOf(t, view.NewAdminAccount(fullAccount()))
+	assertNoKeys(t, keys, "password", "totp_secret")
+	if keys["updated_by"] != "admin@example.com" {
+		t.Errorf("updated_by = %v, want admin@example.com", keys["updated_by"])
+	}
+}
+
+func TestSessionsHideFamily(t *testing.T) {
+	now := time.Now()
+	sessions := []model.Session{
+		{ID: 1, AccountID: 1, FamilyID: "family-1", UserAgent: "curl", IpAddress: "127.0.0.1", LastSeenAt: &now, ExpiresAt: now, CreatedAt: &now},
+		{ID: 2, AccountID: 1, FamilyID: "family-2", UserAgent: "firefox", IpAddress: "127.0.0.2", LastSeenAt: &now, ExpiresAt: now, CreatedAt: &now},
+	}
+	raw, err := json.Marshal(view.NewSessions(sessions, "family-2"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var list []map[string]interface{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(list))
+	}
+	for i, keys := range list {
+		assertNoKeys(t, keys, "password", "totp_secret", "family_id", "account_id", "created_by", "updated_by")
+		if want := i == 1; keys["current"] != want {
+			t.Errorf("session %d current = %v, want %v", i, keys["current"], want)
+		}
+	}
+}
+
+func TestChatboxMessageRole(t *testing.T) {
+	now := time.Now()
+	keys := keysOf(t, view.NewChatboxMessage(model.ChatboxMessage{
+		ID:          1,
+		ChatboxCode: "abc",
+		Role:        "assistant",
+		Content:     "halo",
+		CreatedBy:   "budi@example.com",
+		UpdatedBy:   "budi@example.com",
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}))
+	if keys["role"] != "assistant" {
+		t.Errorf("role = %v, want assistant", keys["role"])
+	}
+	assertNoKeys(t, keys, "name", "created_by", "updated_by")
+}
@@ -0,0 +1,57 @@
+package view
+
+import (
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+)
+
+type Chatbox struct {
+	Code      string     `json:"code"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+func NewChatbox(chatbox model.Chatbox) Chatbox {
+	return Chatbox{
+		Code:      chatbox.Code,
+		Name:      chatbox.Name,
+		CreatedAt: chatbox.CreatedAt,
+		UpdatedAt: chatbox.UpdatedAt,
+	}
+}
+
+func NewChatboxes(chatboxes []model.Chatbox) []Chatbox {
+	views := make([]Chatbox, 0, len(chatboxes))
+	for _, v := range chatboxes {
+		views = append(views, NewChatbox(v))
+	}
+	return views
+}
+
+type ChatboxMessage struct {
+	ID          uint       `json:"id"`
+	ChatboxCode string     `json:"chatbox_code"`
+	Role        string     `json:"role"`
+	Content     string     `json:"content"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+func NewChatboxMessage(message model.ChatboxMessage) ChatboxMessage {
+	return ChatboxMessage{
+		ID:          message.ID,
+		ChatboxCode: message.ChatboxCode,
+		Role:        message.Role,
+		Content:     message.Content,
+		CreatedAt:   message.CreatedAt,
+	}
+}
+
+func NewChatboxMessages(messages []model.ChatboxMessage) []ChatboxMessage {
+	views := make([]ChatboxMessage, 0, len(messages))
+	for _, v := range messages {
+		views = append(views, NewChatboxMessage(v))
+	}
+	return views
+}
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/audit"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/voucher"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type PostRedeemVoucherRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type PostVoucherRequest struct {
+	Code            string     `json:"code" validate:"required"`
+	Type            string     `json:"type" validate:"required,oneof=FREE_DAYS DISCOUNT"`
+	Days            int        `json:"days" validate:"required_if=Type FREE_DAYS,min=0"`
+	DiscountPercent int        `json:"discount_percent" validate:"required_if=Type DISCOUNT,min=0,max=99"`
+	PlanCode        string     `json:"plan_code"`
+	MaxRedemptions  int        `json:"max_redemptions" validate:"min=0"`
+	PerUserLimit    *int       `json:"per_user_limit" validate:"omitempty,min=0"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	Campaign        string     `json:"campaign"`
+	IsActive        *bool      `json:"is_active"`
+}
+
+type VoucherController struct {
+	db        *gorm.DB
+	validator *util.Validator
+}
+
+func NewVoucherController(db *gorm.DB, validator *util.Validator) *VoucherController {
+	return &VoucherController{
+		db:        db,
+		validator: validator,
+	}
+}
+
+// RedeemVoucher	goDocs
+// @Summary      redeem a free premium days voucher
+// @Description  extend the account premium validity, discount vouchers are used at checkout
+// @Tags         Voucher
+// @Produce      application/json
+// @Param        tags body PostRedeemVoucherRequest true "Body Request"
+// @Router       /vouchers/redeem [post]
+func (s *VoucherController) PostRedeem(c *gin.Context) {
+	// bind data
+	var req PostRedeemVoucherRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"voucher": req.Code,
+		"api":     "PostRedeem",
+	})
+
+	username := c.GetString("username")
+	accountRepo := repository.NewAccountRepository(s.db.WithContext(c.Request.Context()))
+	account, result := accountRepo.OneByEmail(username)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find account")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+
+	err := s.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		v, err := voucher.Redeem(tx, req.Code, account, model.VoucherTypeFreeDays, "", "")
+		if err != nil {
+			return err
+		}
+
+		before := account.ValidUntil
+		var result *gorm.DB
+		account, result = repository.NewAccountRepository(tx).ExtendValidUntil(int(account.ID), v.Days)
+		if result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, auditEvent(c, audit.ActionPremiumGrant, account), audit.Changes{
+			"valid_until": {From: before, To: account.ValidUntil},
+			"voucher":     {To: v.Code},
+		})
+	})
+	if err != nil {
+		logCtx.WithField("reason", err).Error("error redeem voucher")
+		if status, ok := voucherErrorStatus(err); ok {
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error redeem voucher"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data": gin.H{
+			"valid_until": account.ValidUntil,
+		},
+	})
+}
+
+func (s *VoucherController) GetVouchers(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetVouchers",
+	})
+
+	voucherRepo := repository.NewVoucherRepository(s.db.WithContext(c.Request.Context()))
+	vouchers, result := voucherRepo.Index(c.Request)
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error find voucher")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error find voucher"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    vouchers,
+		"meta":    voucherRepo.MetaPaginate(c.Request),
+	})
+}
+
+func (s *VoucherController) GetVoucher(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "GetVoucher",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	voucherRepo := repository.NewVoucherRepository(s.db.WithContext(c.Request.Context()))
+	v, result := voucherRepo.OneById(id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error find voucher")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "voucher not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    v,
+	})
+}
+
+func (s *VoucherController) PostVoucher(c *gin.Context) {
+	// bind data
+	var req PostVoucherRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"voucher": req.Code,
+		"api":     "PostVoucher",
+	})
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	perUserLimit := 1
+	if req.PerUserLimit != nil {
+		perUserLimit = *req.PerUserLimit
+	}
+
+	voucherRepo := repository.NewVoucherRepository(s.db.WithContext(c.Request.Context()))
+	v, result := voucherRepo.Create(model.Voucher{
+		Code:            req.Code,
+		Type:            req.Type,
+		Days:            req.Days,
+		DiscountPercent: req.DiscountPercent,
+		PlanCode:        req.PlanCode,
+		MaxRedemptions:  req.MaxRedemptions,
+		PerUserLimit:    perUserLimit,
+		ExpiresAt:       req.ExpiresAt,
+		Campaign:        req.Campaign,
+		IsActive:        isActive,
+	})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error create voucher")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    v,
+	})
+}
+
+func (s *VoucherController) PutVoucher(c *gin.Context) {
+	// bind data
+	var req PostVoucherRequest
+	if err := c.ShouldBind(&req); err != nil {
+		log.WithField("reason", err).Error("error Binding")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// validate
+	if err := s.validator.Validate.Struct(&req); err != nil {
+		log.WithField("reason", err).Error("invalid Request")
+		errs := err.(validator.ValidationErrors)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": errs.Translate(s.validator.Trans)})
+		return
+	}
+
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"voucher": req.Code,
+		"api":     "PutVoucher",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	voucherRepo := repository.NewVoucherRepository(s.db.WithContext(c.Request.Context()))
+	v, result := voucherRepo.Update(id, model.Voucher{
+		Code:            req.Code,
+		Type:            req.Type,
+		Days:            req.Days,
+		DiscountPercent: req.DiscountPercent,
+		PlanCode:        req.PlanCode,
+		MaxRedemptions:  req.MaxRedemptions,
+		ExpiresAt:       req.ExpiresAt,
+		Campaign:        req.Campaign,
+	})
+	if result.Error != nil {
+		logCtx.WithField("reason", result.Error).Error("error update voucher")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+		return
+	}
+	// AssignData skips zero values, so flags and limits are written explicitly
+	values := map[string]interface{}{}
+	if req.IsActive != nil {
+		values["is_active"] = *req.IsActive
+	}
+	if req.PerUserLimit != nil {
+		values["per_user_limit"] = *req.PerUserLimit
+	}
+	if len(values) > 0 {
+		if result := s.db.WithContext(c.Request.Context()).Model(&v).Updates(values); result.Error != nil {
+			logCtx.WithField("reason", result.Error).Error("error update voucher")
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": result.Error.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+		"data":    v,
+	})
+}
+
+func (s *VoucherController) DeleteVoucher(c *gin.Context) {
+	// log
+	logCtx := log.WithFields(log.Fields{
+		"api": "DeleteVoucher",
+	})
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	voucherRepo := repository.NewVoucherRepository(s.db.WithContext(c.Request.Context()))
+	result := voucherRepo.Delete(id, false)
+	if result.Error != nil || result.RowsAffected == 0 {
+		logCtx.WithField("reason", result.Error).Error("error delete voucher")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "voucher not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success!",
+	})
+}
+
+// Map redemption errors to a client status, ok is false for unexpected errors.
+func voucherErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, voucher.ErrNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, voucher.ErrExpired),
+		errors.Is(err, voucher.ErrExhausted),
+		errors.Is(err, voucher.ErrPerUserLimit):
+		return http.StatusConflict, true
+	case errors.Is(err, voucher.ErrWrongType),
+		errors.Is(err, voucher.ErrPlanNotApplicable):
+		return http.StatusUnprocessableEntity, true
+	}
+	return 0, false
+}
@@ -2,24 +2,87 @@ package http
 
 import (
 	"errors"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/avarian/primbon-ajaib-backend/controllers"
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/actor"
+	"github.com/avarian/primbon-ajaib-backend/service/apikey"
+	"github.com/avarian/primbon-ajaib-backend/service/keyring"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/session"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 type JWTClaim struct {
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Type      string `json:"type"`
-	IsPremium bool   `json:"is_premium"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	Type          string   `json:"type"`
+	EmailVerified bool     `json:"email_verified"`
+	SessionID     string   `json:"sid"`
+	TwoFactor     bool     `json:"tfa"`
+	Roles         []string `json:"roles,omitempty"`
+	// Set on impersonation tokens, names the admin acting as the user
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.StandardClaims
 }
 
-func Auth() gin.HandlerFunc {
+// ActorClaim is the RFC 8693 "act" claim
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
+// RequestID tags every request with an id, kept from the X-Request-Id header
+// of a trusted proxy or generated, and echoes it in the response so audit
+// events and logs can be matched to a request.
+func RequestID(trustedProxies []string) gin.HandlerFunc {
+	var trusted []*net.IPNet
+	for _, v := range trustedProxies {
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+		if _, ipNet, err := net.ParseCIDR(v); err == nil {
+			trusted = append(trusted, ipNet)
+		}
+	}
+	fromProxy := func(remoteIP string) bool {
+		ip := net.ParseIP(remoteIP)
+		for _, v := range trusted {
+			if ip != nil && v.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(context *gin.Context) {
+		// only a trusted proxy may pass its request id on, a client could
+		// otherwise plant any text in the audit log
+		requestId := ""
+		if fromProxy(context.RemoteIP()) {
+			requestId = context.GetHeader("X-Request-Id")
+		}
+		if requestId == "" || len(requestId) > 64 {
+			requestId = uuid.New().String()
+		}
+		context.Set("request_id", requestId)
+		context.Header("X-Request-Id", requestId)
+		context.Next()
+	}
+}
+
+func Auth(keys *keyring.Keyring, revoker *session.Revoker, tracker *session.Tracker) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		authorization := context.GetHeader("Authorization")
 		if authorization == "" {
@@ -28,37 +91,171 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 		_, tokenString, _ := strings.Cut(authorization, " ")
-		claims, err := validateToken(tokenString)
+		claims, err := validateToken(keys, tokenString)
 		if err != nil {
 			context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			context.Abort()
 			return
 		}
 
+		// a token that can not be checked may have been revoked, refuse it
+		revoked, err := revoker.IsRevoked(context.Request.Context(), claims.Username, claims.Id, claims.SessionID, claims.IssuedAt)
+		if err != nil {
+			log.WithError(err).WithField("username", claims.Username).Error("error check revocation")
+			context.JSON(http.StatusServiceUnavailable, gin.H{"error": "error check revocation"})
+			context.Abort()
+			return
+		}
+		if revoked {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+			context.Abort()
+			return
+		}
+
+		if err := tracker.Touch(context.Request.Context(), claims.SessionID, context.ClientIP()); err != nil {
+			log.WithError(err).WithField("username", claims.Username).Error("error touch session")
+		}
+
 		context.Set("username", claims.Username)
 		context.Set("type", claims.Type)
-		context.Set("is_premium", claims.IsPremium)
+		context.Set("email_verified", claims.EmailVerified)
+		context.Set("jti", claims.Id)
+		context.Set("session_id", claims.SessionID)
+		context.Set("two_factor", claims.TwoFactor)
+		context.Set("expires_at", claims.ExpiresAt)
+		context.Set("roles", claims.Roles)
+		// audit columns written during the request name this user, or the
+		// admin impersonating them
+		username := claims.Username
+		if claims.Act != nil {
+			context.Set("impersonator", claims.Act.Subject)
+			username = claims.Act.Subject
+		}
+		context.Request = context.Request.WithContext(actor.WithUsername(context.Request.Context(), username))
 		context.Next()
 	}
 }
 
+// ApiKey authenticates partner backends by the X-Api-Key header. The key
+// record is exposed to controllers as "api_key" and its partner name as
+// "partner"; there is no username.
+func ApiKey(apiKeys *apikey.Authenticator) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		plain := context.GetHeader("X-Api-Key")
+		if plain == "" {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an api key"})
+			context.Abort()
+			return
+		}
+		key, err := apiKeys.Authenticate(context.Request.Context(), plain)
+		switch {
+		case errors.Is(err, apikey.ErrRateLimited):
+			context.Header("Retry-After", "60")
+			context.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			context.Abort()
+			return
+		case errors.Is(err, apikey.ErrInvalidKey), errors.Is(err, apikey.ErrExpiredKey):
+			context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			context.Abort()
+			return
+		case err != nil:
+			log.WithError(err).Error("error authenticate api key")
+			context.JSON(http.StatusInternalServerError, gin.H{"error": "error authenticate api key"})
+			context.Abort()
+			return
+		}
+
+		context.Set("api_key", key)
+		context.Set("partner", key.Partner)
+		context.Request = context.Request.WithContext(actor.WithUsername(context.Request.Context(), "api_key:"+key.Prefix))
+		context.Next()
+	}
+}
+
+// ApiKeyOrAuth accepts either a partner api key or a user access token, the
+// X-Api-Key header wins when both are sent.
+func ApiKeyOrAuth(apiKeys *apikey.Authenticator, keys *keyring.Keyring, revoker *session.Revoker, tracker *session.Tracker) gin.HandlerFunc {
+	byApiKey, byToken := ApiKey(apiKeys), Auth(keys, revoker, tracker)
+	return func(context *gin.Context) {
+		if context.GetHeader("X-Api-Key") != "" {
+			byApiKey(context)
+			return
+		}
+		byToken(context)
+	}
+}
+
+// RequireScope refuses partner requests whose api key lacks scope. Users
+// signed in with an access token are not scoped.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		value, ok := context.Get("api_key")
+		if key, isKey := value.(model.ApiKey); ok && isKey && !key.HasScope(scope) {
+			context.JSON(http.StatusForbidden, gin.H{"error": "missing scope " + scope})
+			context.Abort()
+			return
+		}
+		context.Next()
+	}
+}
+
+// Admin lets staff accounts, those holding any role, into the admin area.
+// Each route then asks for its own permission with RequirePermission.
 func Admin() gin.HandlerFunc {
 	return func(context *gin.Context) {
-		typeAccount := context.GetString("type")
-		if typeAccount != "ADMIN" {
+		if len(context.GetStringSlice("roles")) == 0 {
 			context.JSON(http.StatusPreconditionFailed, gin.H{"error": "unauthorized"})
 			context.Abort()
 			return
 		}
+		if viper.GetBool("two_factor.force_admin") && !context.GetBool("two_factor") {
+			context.JSON(http.StatusForbidden, gin.H{"error": "two factor authentication required"})
+			context.Abort()
+			return
+		}
 		context.Next()
 	}
 }
 
-func Premium() gin.HandlerFunc {
+// RequirePermission refuses requests whose roles do not grant permission.
+// Permissions are resolved on every request, so role edits apply at once.
+func RequirePermission(authorizer *rbac.Authorizer, permission string) gin.HandlerFunc {
 	return func(context *gin.Context) {
-		isPremium := context.GetBool("is_premium")
-		if !isPremium {
-			context.JSON(http.StatusPreconditionFailed, gin.H{"error": "unauthorized"})
+		allowed, err := authorizer.Can(context.Request.Context(), context.GetStringSlice("roles"), permission)
+		if err != nil {
+			log.WithError(err).WithField("username", context.GetString("username")).Error("error resolve permissions")
+			context.JSON(http.StatusInternalServerError, gin.H{"error": "error resolve permissions"})
+			context.Abort()
+			return
+		}
+		if !allowed {
+			context.JSON(http.StatusForbidden, gin.H{"error": "missing permission " + permission})
+			context.Abort()
+			return
+		}
+		context.Next()
+	}
+}
+
+// NotImpersonating keeps admins signed in as a customer away from actions
+// only the customer may take, like changing the password or paying.
+func NotImpersonating() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if context.GetString("impersonator") != "" {
+			context.JSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+			context.Abort()
+			return
+		}
+		context.Next()
+	}
+}
+
+// Verified blocks accounts that have not verified their email when the
+// login policy lets them in with limited access.
+func Verified() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !context.GetBool("email_verified") && viper.GetString("email_verification.login_policy") == controllers.LoginPolicyLimit {
+			context.JSON(http.StatusForbidden, gin.H{"error": "email not verified"})
 			context.Abort()
 			return
 		}
@@ -66,14 +263,8 @@ func Premium() gin.HandlerFunc {
 	}
 }
 
-func validateToken(signedToken string) (claims *JWTClaim, err error) {
-	token, err := jwt.ParseWithClaims(
-		signedToken,
-		&JWTClaim{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(viper.GetString("jwt_secret")), nil
-		},
-	)
+func validateToken(keys *keyring.Keyring, signedToken string) (claims *JWTClaim, err error) {
+	token, err := keys.Parse(signedToken, &JWTClaim{})
 	if err != nil {
 		return
 	}
@@ -6,8 +6,13 @@ import (
 	"time"
 
 	"github.com/avarian/primbon-ajaib-backend/controllers"
+	"github.com/avarian/primbon-ajaib-backend/service/apikey"
+	"github.com/avarian/primbon-ajaib-backend/service/keyring"
+	"github.com/avarian/primbon-ajaib-backend/service/rbac"
+	"github.com/avarian/primbon-ajaib-backend/service/session"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 type Server struct {
@@ -18,23 +23,142 @@ type Server struct {
 func NewServer(listenAddress string,
 	home *controllers.HomeController,
 	account *controllers.AccountController,
+	adminAccount *controllers.AdminAccountController,
+	role *controllers.RoleController,
+	auditLog *controllers.AuditController,
+	apiKey *controllers.ApiKeyController,
 	openaiChatbox *controllers.OpenaiChatboxController,
+	payment *controllers.PaymentController,
+	usage *controllers.UsageController,
+	voucher *controllers.VoucherController,
+	referral *controllers.ReferralController,
+	keys *keyring.Keyring,
+	revoker *session.Revoker,
+	tracker *session.Tracker,
+	authorizer *rbac.Authorizer,
+	apiKeys *apikey.Authenticator,
 ) *Server {
 
 	router := gin.Default()
+	// ClientIP only reads X-Forwarded-For from these, the lockout counters
+	// and audit log rely on it
+	trustedProxies := viper.GetStringSlice("trusted_proxies")
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		log.WithField("reason", err).Fatal("invalid trusted_proxies")
+	}
+	router.Use(RequestID(trustedProxies))
 	//
 	// Http Routings
 	//
 	router.GET("/", home.GetHome)
 	router.POST("/register", account.PostRegister)
 	router.POST("/login", account.PostLogin)
-	router.Use(Auth()).POST("/change-pwd", account.PostChangePassword)
+	router.GET("/plans", payment.GetPlans)
+	router.POST("/payment/notification", payment.PostNotification)
+	router.GET("/verify-email", account.GetVerifyEmail)
+	router.GET("/change-email/confirm", account.GetConfirmEmailChange)
+	router.GET("/account/restore", account.GetRestoreAccount)
+	router.POST("/forgot-password", account.PostForgotPassword)
+	router.POST("/reset-password", account.PostResetPassword)
+	router.POST("/login/otp/request", account.PostRequestLoginOtp)
+	router.POST("/login/otp", account.PostLoginOtp)
+	router.POST("/login/2fa", account.PostLoginTwoFactor)
+	router.POST("/token/refresh", account.PostRefreshToken)
+	router.GET("/.well-known/jwks.json", account.GetJWKS)
+	router.GET("/login/oidc/:provider", account.GetOidcLogin)
+	router.GET("/login/oidc/:provider/callback", account.GetOidcCallback)
+
+	partnerRouter := router.Group("/partner").Use(ApiKeyOrAuth(apiKeys, keys, revoker, tracker), RequireScope(apikey.ScopePrimbonRead))
+	{
+		partnerRouter.GET("/me", apiKey.GetPartnerMe)
+	}
+
+	authRouter := router.Group("").Use(Auth(keys, revoker, tracker))
+	{
+		authRouter.POST("/change-pwd", NotImpersonating(), account.PostChangePassword)
+		authRouter.POST("/verify-email/resend", account.PostResendVerifyEmail)
+		authRouter.POST("/phone/verify/request", account.PostRequestVerifyPhone)
+		authRouter.POST("/phone/verify", account.PostVerifyPhone)
+		authRouter.POST("/logout", account.PostLogout)
+		authRouter.POST("/impersonation/end", account.PostEndImpersonation)
+	}
 
-	openaiRouter := router.Group("/openai").Use(Auth())
+	openaiRouter := router.Group("/openai").Use(Auth(keys, revoker, tracker), Verified())
 	{
-		openaiRouter.Use(Premium()).POST("/chatbox", openaiChatbox.PostChatbox)
-		openaiRouter.Use(Premium()).GET("/chatbox/list", openaiChatbox.GetListChatbox)
-		openaiRouter.Use(Premium()).GET("/chatbox/message/:code", openaiChatbox.GetChatboxMessages)
+		openaiRouter.POST("/chatbox", openaiChatbox.PostChatbox)
+		openaiRouter.GET("/chatbox/list", openaiChatbox.GetListChatbox)
+		openaiRouter.GET("/chatbox/message/:code", openaiChatbox.GetChatboxMessages)
+		openaiRouter.GET("/quota", openaiChatbox.GetQuota)
+	}
+
+	paymentRouter := router.Group("/payment").Use(Auth(keys, revoker, tracker), Verified())
+	{
+		paymentRouter.POST("/checkout", NotImpersonating(), payment.PostCheckout)
+		paymentRouter.GET("/orders", payment.GetOrders)
+		paymentRouter.GET("/orders/:code", payment.GetOrder)
+	}
+
+	voucherRouter := router.Group("/vouchers").Use(Auth(keys, revoker, tracker), Verified())
+	{
+		voucherRouter.POST("/redeem", NotImpersonating(), voucher.PostRedeem)
+	}
+
+	meRouter := router.Group("/me").Use(Auth(keys, revoker, tracker))
+	{
+		meRouter.GET("", account.GetMe)
+		meRouter.PATCH("", account.PatchMe)
+		meRouter.POST("/email", NotImpersonating(), account.PostChangeEmail)
+		meRouter.POST("/phone", NotImpersonating(), account.PostChangePhone)
+		meRouter.POST("/phone/confirm", account.PostConfirmPhoneChange)
+		meRouter.GET("/usage", usage.GetMyUsage)
+		meRouter.PUT("/reminders", account.PutReminderPreference)
+		meRouter.GET("/referral", referral.GetMyReferral)
+		meRouter.GET("/sessions", account.GetMySessions)
+		meRouter.DELETE("/sessions", NotImpersonating(), account.DeleteMyOtherSessions)
+		meRouter.DELETE("/sessions/:id", NotImpersonating(), account.DeleteMySession)
+		meRouter.POST("/2fa/enroll", NotImpersonating(), account.PostEnrollTotp)
+		meRouter.POST("/2fa/confirm", NotImpersonating(), account.PostConfirmTotp)
+		meRouter.POST("/2fa/disable", NotImpersonating(), account.PostDisableTotp)
+		meRouter.POST("/2fa/recovery-codes", NotImpersonating(), account.PostRegenerateRecoveryCodes)
+		meRouter.POST("/export", NotImpersonating(), account.PostExportMe)
+		meRouter.POST("/delete", NotImpersonating(), account.PostDeleteMe)
+	}
+
+	adminRouter := router.Group("/admin").Use(Auth(keys, revoker, tracker), Admin())
+	{
+		can := func(permission string) gin.HandlerFunc {
+			return RequirePermission(authorizer, permission)
+		}
+		adminRouter.GET("/usage", can(rbac.PermissionReportsRead), usage.GetUsage)
+		adminRouter.GET("/referrals", can(rbac.PermissionReportsRead), referral.GetReferrals)
+		adminRouter.POST("/plans", can(rbac.PermissionContentWrite), payment.PostPlan)
+		adminRouter.PUT("/plans/:id", can(rbac.PermissionContentWrite), payment.PutPlan)
+		adminRouter.GET("/vouchers", can(rbac.PermissionVouchersRead), voucher.GetVouchers)
+		adminRouter.GET("/vouchers/:id", can(rbac.PermissionVouchersRead), voucher.GetVoucher)
+		adminRouter.POST("/vouchers", can(rbac.PermissionVouchersWrite), voucher.PostVoucher)
+		adminRouter.PUT("/vouchers/:id", can(rbac.PermissionVouchersWrite), voucher.PutVoucher)
+		adminRouter.DELETE("/vouchers/:id", can(rbac.PermissionVouchersWrite), voucher.DeleteVoucher)
+		adminRouter.GET("/accounts", can(rbac.PermissionAccountsRead), adminAccount.GetAccounts)
+		adminRouter.GET("/accounts/:id", can(rbac.PermissionAccountsRead), adminAccount.GetAccount)
+		adminRouter.PATCH("/accounts/:id", can(rbac.PermissionAccountsWrite), adminAccount.PatchAccount)
+		adminRouter.DELETE("/accounts/:id", can(rbac.PermissionAccountsWrite), adminAccount.DeleteAccount)
+		adminRouter.POST("/accounts/:id/restore", can(rbac.PermissionAccountsWrite), adminAccount.PostRestoreAccount)
+		adminRouter.POST("/accounts/:id/suspend", can(rbac.PermissionAccountsWrite), adminAccount.PostSuspendAccount)
+		adminRouter.POST("/accounts/:id/unsuspend", can(rbac.PermissionAccountsWrite), adminAccount.PostUnsuspendAccount)
+		adminRouter.POST("/accounts/:id/reset-password", can(rbac.PermissionAccountsWrite), adminAccount.PostResetAccountPassword)
+		adminRouter.POST("/accounts/:id/unlock", can(rbac.PermissionAccountsWrite), account.PostUnlockAccount)
+		adminRouter.POST("/accounts/:id/impersonate", can(rbac.PermissionAccountsImpersonate), account.PostImpersonate)
+		adminRouter.PUT("/accounts/:id/roles", can(rbac.PermissionRolesWrite), adminAccount.PutAccountRoles)
+		adminRouter.GET("/accounts/:id/chatboxes", can(rbac.PermissionChatsRead), adminAccount.GetAccountChatboxes)
+		adminRouter.GET("/accounts/:id/chatboxes/:code", can(rbac.PermissionChatsRead), adminAccount.GetAccountChatboxMessages)
+		adminRouter.GET("/roles", can(rbac.PermissionRolesWrite), role.GetRoles)
+		adminRouter.PUT("/roles/:code/permissions", can(rbac.PermissionRolesWrite), role.PutRolePermissions)
+		adminRouter.GET("/audit-events", can(rbac.PermissionAuditRead), auditLog.GetAuditEvents)
+		adminRouter.GET("/audit-events/export", can(rbac.PermissionAuditRead), auditLog.GetAuditEventsExport)
+		adminRouter.GET("/api-keys", can(rbac.PermissionApiKeysWrite), apiKey.GetApiKeys)
+		adminRouter.POST("/api-keys", can(rbac.PermissionApiKeysWrite), apiKey.PostApiKey)
+		adminRouter.PATCH("/api-keys/:id", can(rbac.PermissionApiKeysWrite), apiKey.PatchApiKey)
+		adminRouter.DELETE("/api-keys/:id", can(rbac.PermissionApiKeysWrite), apiKey.DeleteApiKey)
 	}
 
 	httpServer := &http.Server{
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var AccountDeletedJobQueueId = "account_deleted"
+
+var accountDeletedEmailTemplate = template.Must(template.New("account_deleted_email").Parse(`<p>Halo {{.Name}},</p>
+<p>Akun Primbon Ajaib kamu sudah dihapus. Seluruh data kamu akan dihapus permanen pada {{.PurgeAt}}.</p>
+<p>Berubah pikiran? Pulihkan akun sebelum tanggal tersebut melalui tautan berikut:</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>`))
+
+// AccountDeletedJob confirms a deletion and offers the restore link that
+// works during the grace period.
+type AccountDeletedJob struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Link    string `json:"link"`
+	PurgeAt string `json:"purge_at"`
+}
+
+func NewAccountDeletedJob(name string, email string, link string, purgeAt string) *AccountDeletedJob {
+	return &AccountDeletedJob{
+		Name:    name,
+		Email:   email,
+		Link:    link,
+		PurgeAt: purgeAt,
+	}
+}
+
+// Return the queue id for this job
+func (j *AccountDeletedJob) QueueID() string { return AccountDeletedJobQueueId }
+
+func (j *AccountDeletedJob) Handle(ctx context.Context) error {
+	if mailer == nil {
+		return errServiceUninitialized
+	}
+
+	var body bytes.Buffer
+	if err := accountDeletedEmailTemplate.Execute(&body, j); err != nil {
+		return err
+	}
+
+	log.WithField("email", j.Email).Info("Processing AccountDeletedJob.Handle()")
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Akun Primbon Ajaib kamu dihapus", j.Email, body.String())
+}
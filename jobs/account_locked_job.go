@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var AccountLockedJobQueueId = "account_locked"
+
+var accountLockedEmailTemplate = template.Must(template.New("account_locked_email").Parse(`<p>Halo {{.Name}},</p>
+<p>Kami mendeteksi terlalu banyak percobaan masuk yang gagal ke akun Primbon Ajaib kamu, percobaan terakhir dari alamat IP {{.IpAddress}}. Demi keamanan, akun kamu dikunci sementara selama {{.Minutes}} menit.</p>
+<p>Jika itu bukan kamu, segera atur ulang kata sandi setelah kunci dibuka.</p>`))
+
+// AccountLockedJob tells the owner that failed logins locked the account.
+type AccountLockedJob struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	IpAddress string `json:"ip_address"`
+	Minutes   int    `json:"minutes"`
+}
+
+func NewAccountLockedJob(name string, email string, ipAddress string, minutes int) *AccountLockedJob {
+	return &AccountLockedJob{
+		Name:      name,
+		Email:     email,
+		IpAddress: ipAddress,
+		Minutes:   minutes,
+	}
+}
+
+// Return the queue id for this job
+func (j *AccountLockedJob) QueueID() string { return AccountLockedJobQueueId }
+
+func (j *AccountLockedJob) Handle(ctx context.Context) error {
+	if mailer == nil {
+		return errServiceUninitialized
+	}
+
+	var body bytes.Buffer
+	if err := accountLockedEmailTemplate.Execute(&body, j); err != nil {
+		return err
+	}
+
+	log.WithField("email", j.Email).Info("Processing AccountLockedJob.Handle()")
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Akun Primbon Ajaib kamu dikunci sementara", j.Email, body.String())
+}
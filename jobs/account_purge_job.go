@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var AccountPurgeJobQueueId = "account_purge"
+
+// AccountPurgeJob hard deletes the accounts their owner deleted more than
+// GraceDays ago, with their personal data and the data export archives
+// left in the bucket. Payment orders and voucher redemptions are kept for
+// bookkeeping; they only carry the account id.
+type AccountPurgeJob struct {
+	GraceDays int `json:"grace_days"`
+}
+
+func NewAccountPurgeJob(graceDays int) *AccountPurgeJob {
+	return &AccountPurgeJob{
+		GraceDays: graceDays,
+	}
+}
+
+// Return the queue id for this job
+func (j *AccountPurgeJob) QueueID() string { return AccountPurgeJobQueueId }
+
+func (j *AccountPurgeJob) Handle(ctx context.Context) error {
+	if db == nil || store == nil {
+		return errServiceUninitialized
+	}
+
+	before := time.Now().AddDate(0, 0, -j.GraceDays)
+	accounts, result := repository.NewAccountRepository(db).AllDeletionRequestedBefore(before)
+	if result.Error != nil {
+		return result.Error
+	}
+	log.WithField("accounts", len(accounts)).Info("Processing AccountPurgeJob.Handle()")
+
+	for _, account := range accounts {
+		if err := purgeAccount(ctx, account); err != nil {
+			log.WithError(err).WithField("account_id", account.ID).Error("error purge account")
+			continue
+		}
+		log.WithField("account_id", account.ID).Info("account purged")
+	}
+	return nil
+}
+
+func purgeAccount(ctx context.Context, account model.Account) error {
+	// archives go first, the account stays to retry on the next run if the
+	// bucket is unreachable
+	if err := store.DeletePrefix(ctx, fmt.Sprintf("exports/%d/", account.ID)); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		chatboxes, result := repository.NewChatboxRepository(tx.Unscoped()).AllByAccountID(int(account.ID))
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(chatboxes) > 0 {
+			codes := make([]string, 0, len(chatboxes))
+			for _, v := range chatboxes {
+				codes = append(codes, v.Code)
+			}
+			if result := repository.NewChatboxMessageRepository(tx).PurgeByChatboxCodes(codes); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		if err := repository.PurgeByAccountID(tx, int(account.ID),
+			&model.Chatbox{},
+			&model.UsageRecord{},
+			&model.Session{},
+			&model.RefreshToken{},
+			&model.VerificationToken{},
+			&model.RecoveryCode{},
+			&model.ReminderLog{},
+			&model.AccountIdentity{},
+			&model.AccountRole{},
+		); err != nil {
+			return err
+		}
+		if result := repository.NewReferralRepository(tx).PurgeByAccountID(int(account.ID)); result.Error != nil {
+			return result.Error
+		}
+		return repository.NewAccountRepository(tx).Delete(int(account.ID), true).Error
+	})
+}
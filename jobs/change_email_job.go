@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ChangeEmailJobQueueId = "change_email"
+
+var changeEmailTemplate = template.Must(template.New("change_email").Parse(`<p>Halo {{.Name}},</p>
+<p>Kami menerima permintaan untuk mengganti email akun Primbon Ajaib kamu ke alamat ini. Konfirmasi perubahan dengan membuka tautan berikut:</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>Email akun tidak akan berubah sebelum dikonfirmasi. Abaikan email ini jika kamu tidak merasa memintanya.</p>`))
+
+// ChangeEmailJob sends the confirmation link of an email change to the new
+// address.
+type ChangeEmailJob struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Link  string `json:"link"`
+}
+
+func NewChangeEmailJob(name string, email string, link string) *ChangeEmailJob {
+	return &ChangeEmailJob{
+		Name:  name,
+		Email: email,
+		Link:  link,
+	}
+}
+
+// Return the queue id for this job
+func (j *ChangeEmailJob) QueueID() string { return ChangeEmailJobQueueId }
+
+func (j *ChangeEmailJob) Handle(ctx context.Context) error {
+	if mailer == nil {
+		return errServiceUninitialized
+	}
+
+	var body bytes.Buffer
+	if err := changeEmailTemplate.Execute(&body, j); err != nil {
+		return err
+	}
+
+	log.WithField("email", j.Email).Info("Processing ChangeEmailJob.Handle()")
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Konfirmasi perubahan email Primbon Ajaib", j.Email, body.String())
+}
@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ContactChangedJobQueueId = "contact_changed"
+
+var contactChangedEmailTemplate = template.Must(template.New("contact_changed_email").Parse(`<p>Halo {{.Name}},</p>
+<p>{{if eq .Field "email"}}Email{{else}}Nomor telepon{{end}} akun Primbon Ajaib kamu baru saja diganti menjadi {{.NewValue}}.</p>
+<p>Jika kamu tidak melakukan perubahan ini, segera hubungi kami.</p>`))
+
+var contactChangedSMSTemplate = template.Must(template.New("contact_changed_sms").Parse(
+	`Primbon Ajaib: {{if eq .Field "email"}}email{{else}}nomor telepon{{end}} akun kamu diganti menjadi {{.NewValue}}. Hubungi kami jika bukan kamu.`))
+
+// ContactChangedJob tells the previous email or phone number of an account
+// that it was replaced.
+type ContactChangedJob struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+	// email or phone_number
+	Field    string `json:"field"`
+	NewValue string `json:"new_value"`
+}
+
+func NewContactChangedJob(name string, email string, phoneNumber string, field string, newValue string) *ContactChangedJob {
+	return &ContactChangedJob{
+		Name:        name,
+		Email:       email,
+		PhoneNumber: phoneNumber,
+		Field:       field,
+		NewValue:    newValue,
+	}
+}
+
+// Return the queue id for this job
+func (j *ContactChangedJob) QueueID() string { return ContactChangedJobQueueId }
+
+func (j *ContactChangedJob) Handle(ctx context.Context) error {
+	var body bytes.Buffer
+
+	if j.PhoneNumber != "" {
+		if messenger == nil {
+			return errServiceUninitialized
+		}
+		if err := contactChangedSMSTemplate.Execute(&body, j); err != nil {
+			return err
+		}
+		log.WithField("phone_number", j.PhoneNumber).Info("Processing ContactChangedJob.Handle()")
+		return messenger.SendSMS(j.PhoneNumber, body.String())
+	}
+
+	if mailer == nil {
+		return errServiceUninitialized
+	}
+	if err := contactChangedEmailTemplate.Execute(&body, j); err != nil {
+		return err
+	}
+	log.WithField("email", j.Email).Info("Processing ContactChangedJob.Handle()")
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Data kontak akun Primbon Ajaib diganti", j.Email, body.String())
+}
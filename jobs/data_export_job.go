@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/export"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+var DataExportJobQueueId = "data_export"
+
+var dataExportEmailTemplate = template.Must(template.New("data_export_email").Parse(`<p>Halo {{.Name}},</p>
+<p>Salinan data pribadi akun Primbon Ajaib kamu sudah siap. Unduh arsipnya melalui tautan berikut:</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>Tautan ini berlaku sampai {{.ExpiresAt}}. Jangan bagikan tautan ini kepada siapa pun.</p>`))
+
+type dataExportData struct {
+	Name      string
+	Link      string
+	ExpiresAt string
+}
+
+// DataExportJob builds the personal data archive of an account, stores it
+// in the bucket and emails the owner a download link.
+type DataExportJob struct {
+	AccountID uint `json:"account_id"`
+	// Hours the download link stays valid
+	LinkTTL int `json:"link_ttl"`
+}
+
+func NewDataExportJob(accountId uint, linkTTL int) *DataExportJob {
+	return &DataExportJob{
+		AccountID: accountId,
+		LinkTTL:   linkTTL,
+	}
+}
+
+// Return the queue id for this job
+func (j *DataExportJob) QueueID() string { return DataExportJobQueueId }
+
+func (j *DataExportJob) Handle(ctx context.Context) error {
+	if db == nil || mailer == nil || store == nil {
+		return errServiceUninitialized
+	}
+
+	logCtx := log.WithField("account_id", j.AccountID)
+	logCtx.Info("Processing DataExportJob.Handle()")
+
+	account, result := repository.NewAccountRepository(db).OneById(int(j.AccountID))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		logCtx.Warn("account gone before export")
+		return nil
+	}
+
+	archive, err := export.Build(db, account.ID)
+	if err != nil {
+		return err
+	}
+
+	// random key so links can not be guessed from the account id
+	key := fmt.Sprintf("exports/%d/%s.zip", account.ID, uuid.New().String())
+	if err := store.Put(ctx, key, archive, "application/zip"); err != nil {
+		return err
+	}
+	ttl := time.Duration(j.LinkTTL) * time.Hour
+	link, err := store.PresignGet(key, ttl)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := dataExportEmailTemplate.Execute(&body, dataExportData{
+		Name:      account.Name,
+		Link:      link,
+		ExpiresAt: time.Now().Add(ttl).Format("02-01-2006 15:04"),
+	}); err != nil {
+		return err
+	}
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Salinan data akun Primbon Ajaib", account.Email, body.String())
+}
@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+var PremiumReminderJobQueueId = "premium_reminder"
+
+// Days ahead of ValidUntil a reminder is sent
+var PremiumReminderDays = []int{7, 3, 1}
+
+var premiumReminderEmail = template.Must(template.New("email").Parse(`<p>Halo {{.Name}},</p>
+<p>Langganan premium Primbon Ajaib kamu akan berakhir dalam {{.Days}} hari, pada {{.ValidUntil}}.</p>
+<p>Perpanjang sekarang supaya tetap bisa berkonsultasi tanpa batas.</p>`))
+
+var premiumReminderSMS = template.Must(template.New("sms").Parse(
+	`Primbon Ajaib: premium kamu berakhir dalam {{.Days}} hari ({{.ValidUntil}}). Perpanjang sekarang agar tidak terputus.`))
+
+type premiumReminderData struct {
+	Name       string
+	Days       int
+	ValidUntil string
+}
+
+type PremiumReminderJob struct {
+	// Date the reminders are computed for, defaults to today
+	Date string `json:"date"`
+}
+
+func NewPremiumReminderJob() *PremiumReminderJob {
+	return &PremiumReminderJob{
+		Date: time.Now().Format("2006-01-02"),
+	}
+}
+
+// Return the queue id for this job
+func (j *PremiumReminderJob) QueueID() string { return PremiumReminderJobQueueId }
+
+// Send expiry reminders for accounts whose premium ends 7, 3 and 1 days ahead.
+// Every reminder is recorded in ReminderLog before it is sent; the unique
+// index makes a reminder go out once even if the job runs again.
+func (j *PremiumReminderJob) Handle(ctx context.Context) error {
+	if db == nil || mailer == nil || messenger == nil {
+		return errServiceUninitialized
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", j.Date, time.Local)
+	if err != nil {
+		now := time.Now()
+		date = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	}
+
+	accountRepo := repository.NewAccountRepository(db)
+	for _, days := range PremiumReminderDays {
+		validUntil := date.AddDate(0, 0, days)
+		accounts, result := accountRepo.AllByValidUntil(validUntil)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, account := range accounts {
+			if account.ReminderOptOut {
+				continue
+			}
+			data := premiumReminderData{
+				Name:       account.Name,
+				Days:       days,
+				ValidUntil: validUntil.Format("02-01-2006"),
+			}
+			// only to addresses the owner confirmed
+			if account.Email != "" && account.EmailVerifiedAt != nil {
+				j.send(account, days, validUntil, "email", func() error {
+					var body bytes.Buffer
+					if err := premiumReminderEmail.Execute(&body, data); err != nil {
+						return err
+					}
+					return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Premium kamu segera berakhir", account.Email, body.String())
+				})
+			}
+			if account.Phone() != "" && account.PhoneVerifiedAt != nil {
+				j.send(account, days, validUntil, "sms", func() error {
+					var text bytes.Buffer
+					if err := premiumReminderSMS.Execute(&text, data); err != nil {
+						return err
+					}
+					return messenger.SendSMS(account.Phone(), text.String())
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (j *PremiumReminderJob) send(account model.Account, days int, validUntil time.Time, channel string, send func() error) {
+	logCtx := log.WithFields(log.Fields{
+		"account": account.ID,
+		"days":    days,
+		"channel": channel,
+	})
+
+	reminderLogRepo := repository.NewReminderLogRepository(db)
+	reminderLog, result := reminderLogRepo.Create(model.ReminderLog{
+		AccountID:  account.ID,
+		Kind:       model.ReminderKindPremiumExpiry,
+		DaysBefore: days,
+		ValidUntil: datatypes.Date(validUntil),
+		Channel:    channel,
+	})
+	if result.Error != nil {
+		// already recorded by an earlier run
+		logCtx.WithField("reason", result.Error).Debug("reminder skipped")
+		return
+	}
+
+	if err := send(); err != nil {
+		logCtx.WithField("reason", err).Error("error send reminder")
+		reminderLogRepo.Delete(int(reminderLog.ID), true)
+		return
+	}
+	logCtx.Info("reminder sent")
+}
@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ResetPasswordJobQueueId = "reset_password"
+
+var resetPasswordEmailTemplate = template.Must(template.New("reset_password_email").Parse(`<p>Halo {{.Name}},</p>
+<p>Kami menerima permintaan untuk mengatur ulang kata sandi akun Primbon Ajaib kamu. Buka tautan berikut untuk membuat kata sandi baru:</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>Abaikan email ini jika kamu tidak merasa memintanya.</p>`))
+
+var resetPasswordSMSTemplate = template.Must(template.New("reset_password_sms").Parse(
+	`Primbon Ajaib: atur ulang kata sandi kamu di {{.Link}} . Abaikan jika bukan kamu.`))
+
+// ResetPasswordJob delivers a reset link by email, or by SMS when the
+// request was made with a phone number.
+type ResetPasswordJob struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+	Link        string `json:"link"`
+}
+
+func NewResetPasswordJob(name string, email string, phoneNumber string, link string) *ResetPasswordJob {
+	return &ResetPasswordJob{
+		Name:        name,
+		Email:       email,
+		PhoneNumber: phoneNumber,
+		Link:        link,
+	}
+}
+
+// Return the queue id for this job
+func (j *ResetPasswordJob) QueueID() string { return ResetPasswordJobQueueId }
+
+func (j *ResetPasswordJob) Handle(ctx context.Context) error {
+	var body bytes.Buffer
+
+	if j.PhoneNumber != "" {
+		if messenger == nil {
+			return errServiceUninitialized
+		}
+		if err := resetPasswordSMSTemplate.Execute(&body, j); err != nil {
+			return err
+		}
+		log.WithField("phone_number", j.PhoneNumber).Info("Processing ResetPasswordJob.Handle()")
+		return messenger.SendSMS(j.PhoneNumber, body.String())
+	}
+
+	if mailer == nil {
+		return errServiceUninitialized
+	}
+	if err := resetPasswordEmailTemplate.Execute(&body, j); err != nil {
+		return err
+	}
+	log.WithField("email", j.Email).Info("Processing ResetPasswordJob.Handle()")
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Atur ulang kata sandi Primbon Ajaib", j.Email, body.String())
+}
@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"errors"
+
+	"github.com/avarian/primbon-ajaib-backend/service/storage"
+	"github.com/avarian/primbon-ajaib-backend/util"
+	"gorm.io/gorm"
+)
+
+// Services used by job handlers, assigned by the worker command on start up
+var (
+	db        *gorm.DB
+	mailer    util.Mailer
+	messenger util.Messenger
+	mailFrom  MailFrom
+	store     *storage.Storage
+)
+
+type MailFrom struct {
+	Name    string
+	Address string
+}
+
+var errServiceUninitialized = errors.New("job service is uninitialized")
+
+func SetDB(d *gorm.DB) {
+	db = d
+}
+
+func SetMailer(m util.Mailer, from MailFrom) {
+	mailer = m
+	mailFrom = from
+}
+
+func SetMessenger(m util.Messenger) {
+	messenger = m
+}
+
+func SetStorage(s *storage.Storage) {
+	store = s
+}
@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var VerifyEmailJobQueueId = "verify_email"
+
+var verifyEmailTemplate = template.Must(template.New("verify_email").Parse(`<p>Halo {{.Name}},</p>
+<p>Terima kasih sudah mendaftar di Primbon Ajaib. Konfirmasi alamat email kamu dengan membuka tautan berikut:</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>Tautan ini hanya berlaku satu kali dan akan kedaluwarsa.</p>`))
+
+type VerifyEmailJob struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Link  string `json:"link"`
+}
+
+func NewVerifyEmailJob(name string, email string, link string) *VerifyEmailJob {
+	return &VerifyEmailJob{
+		Name:  name,
+		Email: email,
+		Link:  link,
+	}
+}
+
+// Return the queue id for this job
+func (j *VerifyEmailJob) QueueID() string { return VerifyEmailJobQueueId }
+
+func (j *VerifyEmailJob) Handle(ctx context.Context) error {
+	if mailer == nil {
+		return errServiceUninitialized
+	}
+
+	var body bytes.Buffer
+	if err := verifyEmailTemplate.Execute(&body, j); err != nil {
+		return err
+	}
+
+	log.WithField("email", j.Email).Info("Processing VerifyEmailJob.Handle()")
+	return mailer.SendEmail(mailFrom.Name, mailFrom.Address, "Verifikasi email Primbon Ajaib", j.Email, body.String())
+}
@@ -7,19 +7,46 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	AccountTypeCustomer = "CUSTOMER"
+	AccountTypeAdmin    = "ADMIN"
+)
+
 type Account struct {
-	ID          uint            `json:"id" gorm:"not null"`
-	Name        string          `json:"name" gorm:"not null;size:255"`
-	Email       string          `json:"email" gorm:"size:255;unique"`
-	PhoneNumber string          `json:"phone_number" gorm:"size:255;unique"`
-	Password    string          `json:"password" gorm:"size:255"`
-	Address     string          `json:"address" gorm:"size:255"`
-	Type        string          `json:"type" gorm:"size:255"`
-	ValidUntil  datatypes.Date  `json:"valid_until"`
-	CreatedBy   string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
-	UpdatedBy   string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
-	DeletedBy   *string         `json:"deleted_by" gorm:"size:255"`
-	CreatedAt   *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt   *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
-	DeletedAt   *gorm.DeletedAt `json:"deleted_at"`
+	ID                uint           `json:"id" gorm:"not null"`
+	Name              string         `json:"name" gorm:"not null;size:255"`
+	Email             string         `json:"email" gorm:"size:255;unique"`
+	PhoneNumber       *string        `json:"phone_number" gorm:"size:255;unique"`
+	Password          string         `json:"-" gorm:"size:255"`
+	Address           string         `json:"address" gorm:"size:255"`
+	Type              string         `json:"type" gorm:"size:255"`
+	PasswordChangedAt *time.Time     `json:"password_changed_at"`
+	EmailVerifiedAt   *time.Time     `json:"email_verified_at"`
+	PhoneVerifiedAt   *time.Time     `json:"phone_verified_at"`
+	TotpSecret        string         `json:"-" gorm:"size:64"`
+	TotpEnabledAt     *time.Time     `json:"totp_enabled_at"`
+	ValidUntil        datatypes.Date `json:"valid_until"`
+	PlanCode          string         `json:"plan_code" gorm:"size:255"`
+	ReferralCode      *string        `json:"referral_code" gorm:"size:32;unique"`
+	ReferredByID      *uint          `json:"referred_by_id"`
+	ReminderOptOut    bool           `json:"reminder_opt_out" gorm:"not null"`
+	SuspendedAt       *time.Time     `json:"suspended_at"`
+	SuspendedReason   string         `json:"suspended_reason" gorm:"size:255"`
+	// Set when the owner deleted the account, only these are purged
+	DeletionRequestedAt *time.Time      `json:"deletion_requested_at"`
+	CreatedBy           string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy           string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy           *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt           *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt           *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt           *gorm.DeletedAt `json:"deleted_at"`
+}
+
+// Phone returns the phone number, "" when the account has none. Accounts
+// from social login start without one, kept NULL so the unique index holds.
+func (a Account) Phone() string {
+	if a.PhoneNumber == nil {
+		return ""
+	}
+	return *a.PhoneNumber
 }
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountIdentity links an account to its user at an OpenID provider, so
+// later social logins find the account even if the email changes.
+type AccountIdentity struct {
+	ID        uint            `json:"id" gorm:"not null"`
+	AccountID uint            `json:"account_id" gorm:"not null;index"`
+	Provider  string          `json:"provider" gorm:"not null;size:64;uniqueIndex:idx_account_identity"`
+	Subject   string          `json:"subject" gorm:"not null;size:255;uniqueIndex:idx_account_identity"`
+	Email     string          `json:"email" gorm:"size:255"`
+	CreatedBy string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApiKey lets a partner backend call the API without a user account. The
+// key handed out is "<Prefix>.<secret>", only the SHA-256 hash of the secret
+// is stored and the prefix identifies the key in lists and logs. Scopes are
+// space separated like OAuth scopes; a RateLimit of 0 uses the configured
+// requests per minute.
+type ApiKey struct {
+	ID         uint            `json:"id" gorm:"not null"`
+	Partner    string          `json:"partner" gorm:"not null;size:255"`
+	Prefix     string          `json:"prefix" gorm:"not null;size:32;unique"`
+	SecretHash string          `json:"-" gorm:"not null;size:64"`
+	Scopes     string          `json:"scopes" gorm:"not null;size:512"`
+	RateLimit  int             `json:"rate_limit" gorm:"not null;default:0"`
+	LastUsedAt *time.Time      `json:"last_used_at"`
+	ExpiresAt  *time.Time      `json:"expires_at"`
+	CreatedBy  string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy  string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy  *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt  *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt  *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt  *gorm.DeletedAt `json:"deleted_at"`
+}
+
+func (k ApiKey) HasScope(scope string) bool {
+	for _, v := range strings.Fields(k.Scopes) {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	AuditTargetAccount = "ACCOUNT"
+	AuditTargetRole    = "ROLE"
+	AuditTargetSession = "SESSION"
+	AuditTargetApiKey  = "API_KEY"
+)
+
+// AuditEvent is an append-only record of a sensitive action. Rows are never
+// updated or deleted, so the table has no audit or soft delete columns.
+type AuditEvent struct {
+	ID         uint           `json:"id" gorm:"not null"`
+	Actor      string         `json:"actor" gorm:"not null;size:255;index"`
+	Action     string         `json:"action" gorm:"not null;size:64;index"`
+	TargetType string         `json:"target_type" gorm:"size:64;index:idx_audit_event_target"`
+	TargetID   *uint          `json:"target_id" gorm:"index:idx_audit_event_target"`
+	IpAddress  string         `json:"ip_address" gorm:"size:64"`
+	UserAgent  string         `json:"user_agent" gorm:"size:512"`
+	RequestID  string         `json:"request_id" gorm:"size:64;index"`
+	Changes    datatypes.JSON `json:"changes"`
+	CreatedAt  *time.Time     `json:"created_at" gorm:"default:current_timestamp;index"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentOrderStatusPending  = "PENDING"
+	PaymentOrderStatusPaid     = "PAID"
+	PaymentOrderStatusExpired  = "EXPIRED"
+	PaymentOrderStatusRefunded = "REFUNDED"
+)
+
+type PaymentOrder struct {
+	ID          uint            `json:"id" gorm:"not null"`
+	Code        string          `json:"code" gorm:"not null;size:255;unique"`
+	AccountID   uint            `json:"account_id" gorm:"not null;index"`
+	PlanCode    string          `json:"plan_code" gorm:"not null;size:255"`
+	Amount      int64           `json:"amount" gorm:"not null"`
+	Days        int             `json:"days" gorm:"not null"`
+	Status      string          `json:"status" gorm:"not null;size:255;default:PENDING"`
+	Provider    string          `json:"provider" gorm:"size:255"`
+	ProviderRef string          `json:"provider_ref" gorm:"size:255"`
+	PaymentUrl  string          `json:"payment_url" gorm:"size:1024"`
+	PaidAt      *time.Time      `json:"paid_at"`
+	CreatedBy   string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy   string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy   *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt   *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt   *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Plan struct {
+	ID             uint            `json:"id" gorm:"not null"`
+	Code           string          `json:"code" gorm:"not null;size:255;unique"`
+	Name           string          `json:"name" gorm:"not null;size:255"`
+	Price          int64           `json:"price" gorm:"not null"`
+	Days           int             `json:"days" gorm:"not null"`
+	IsActive       bool            `json:"is_active" gorm:"not null"`
+	MessagesPerDay int             `json:"messages_per_day" gorm:"not null;default:0"`
+	MaxChatboxes   int             `json:"max_chatboxes" gorm:"not null;default:0"`
+	CreatedBy      string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy      string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy      *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt      *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt      *gorm.DeletedAt `json:"deleted_at"`
+}
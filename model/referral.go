@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReferralStatusPending  = "PENDING"
+	ReferralStatusRewarded = "REWARDED"
+	ReferralStatusRejected = "REJECTED"
+)
+
+type Referral struct {
+	ID           uint            `json:"id" gorm:"not null"`
+	ReferrerID   uint            `json:"referrer_id" gorm:"not null;index"`
+	RefereeID    uint            `json:"referee_id" gorm:"not null;unique"`
+	RefereeEmail string          `json:"-" gorm:"not null;size:255;index"`
+	RefereePhone string          `json:"-" gorm:"not null;size:255;index"`
+	Status       string          `json:"status" gorm:"not null;size:255"`
+	RejectReason string          `json:"reject_reason" gorm:"size:255"`
+	RewardDays   int             `json:"reward_days" gorm:"not null"`
+	RewardedAt   *time.Time      `json:"rewarded_at"`
+	CreatedBy    string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy    string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy    *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt    *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt    *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const ReminderKindPremiumExpiry = "PREMIUM_EXPIRY"
+
+type ReminderLog struct {
+	ID         uint            `json:"id" gorm:"not null"`
+	AccountID  uint            `json:"account_id" gorm:"not null;uniqueIndex:idx_reminder_log"`
+	Kind       string          `json:"kind" gorm:"not null;size:64;uniqueIndex:idx_reminder_log"`
+	DaysBefore int             `json:"days_before" gorm:"not null;uniqueIndex:idx_reminder_log"`
+	ValidUntil datatypes.Date  `json:"valid_until" gorm:"not null;uniqueIndex:idx_reminder_log"`
+	Channel    string          `json:"channel" gorm:"not null;size:64;uniqueIndex:idx_reminder_log"`
+	CreatedBy  string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy  string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy  *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt  *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt  *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt  *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RoleAdmin         = "ADMIN"
+	RoleSupport       = "SUPPORT"
+	RoleContentEditor = "CONTENT_EDITOR"
+	RoleFinance       = "FINANCE"
+)
+
+// Role groups the permissions granted to staff accounts.
+type Role struct {
+	ID          uint             `json:"id" gorm:"not null"`
+	Code        string           `json:"code" gorm:"not null;size:64;unique"`
+	Name        string           `json:"name" gorm:"not null;size:255"`
+	Permissions []RolePermission `json:"permissions"`
+	CreatedBy   string           `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy   string           `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy   *string          `json:"deleted_by" gorm:"size:255"`
+	CreatedAt   *time.Time       `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   *time.Time       `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt   *gorm.DeletedAt  `json:"deleted_at"`
+}
+
+type RolePermission struct {
+	ID         uint            `json:"id" gorm:"not null"`
+	RoleID     uint            `json:"role_id" gorm:"not null;uniqueIndex:idx_role_permission"`
+	Permission string          `json:"permission" gorm:"not null;size:64;uniqueIndex:idx_role_permission"`
+	CreatedBy  string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy  string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy  *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt  *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt  *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt  *gorm.DeletedAt `json:"deleted_at"`
+}
+
+type AccountRole struct {
+	ID        uint            `json:"id" gorm:"not null"`
+	AccountID uint            `json:"account_id" gorm:"not null;uniqueIndex:idx_account_role"`
+	RoleID    uint            `json:"role_id" gorm:"not null;uniqueIndex:idx_account_role"`
+	Role      Role            `json:"role"`
+	CreatedBy string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt *gorm.DeletedAt `json:"deleted_at"`
+}
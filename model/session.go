@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a signed in device. Its FamilyID is shared by the refresh
+// tokens of the session and carried as sid in the access tokens.
+type Session struct {
+	ID         uint            `json:"id" gorm:"not null"`
+	AccountID  uint            `json:"account_id" gorm:"not null;index"`
+	FamilyID   string          `json:"-" gorm:"not null;size:64;unique"`
+	UserAgent  string          `json:"user_agent" gorm:"size:512"`
+	IpAddress  string          `json:"ip_address" gorm:"size:64"`
+	TwoFactor  bool            `json:"two_factor" gorm:"not null"`
+	LastSeenAt *time.Time      `json:"last_seen_at"`
+	ExpiresAt  time.Time       `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time      `json:"revoked_at"`
+	CreatedBy  string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy  string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy  *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt  *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt  *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt  *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UsageRecord struct {
+	ID               uint            `json:"id" gorm:"not null"`
+	AccountID        uint            `json:"account_id" gorm:"not null;index"`
+	ChatboxCode      string          `json:"chatbox_code" gorm:"not null;size:255;index"`
+	Model            string          `json:"model" gorm:"not null;size:255"`
+	Persona          string          `json:"persona" gorm:"not null;size:255"`
+	PromptTokens     int             `json:"prompt_tokens" gorm:"not null"`
+	CompletionTokens int             `json:"completion_tokens" gorm:"not null"`
+	TotalTokens      int             `json:"total_tokens" gorm:"not null"`
+	Cost             float64         `json:"cost" gorm:"not null;type:decimal(14,6)"`
+	CreatedBy        string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy        string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy        *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt        *time.Time      `json:"created_at" gorm:"default:current_timestamp;index"`
+	UpdatedAt        *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt        *gorm.DeletedAt `json:"deleted_at"`
+}
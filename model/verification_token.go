@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TokenPurposeEmailVerification = "EMAIL_VERIFICATION"
+	TokenPurposePasswordReset     = "PASSWORD_RESET"
+	TokenPurposeEmailChange       = "EMAIL_CHANGE"
+	TokenPurposeAccountRestore    = "ACCOUNT_RESTORE"
+)
+
+type VerificationToken struct {
+	ID        uint            `json:"id" gorm:"not null"`
+	AccountID uint            `json:"account_id" gorm:"not null;index"`
+	Purpose   string          `json:"purpose" gorm:"not null;size:64"`
+	TokenHash string          `json:"-" gorm:"not null;size:64;unique"`
+	Target    string          `json:"target" gorm:"size:255"`
+	ExpiresAt time.Time       `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time      `json:"used_at"`
+	CreatedBy string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	VoucherTypeFreeDays = "FREE_DAYS"
+	VoucherTypeDiscount = "DISCOUNT"
+)
+
+type Voucher struct {
+	ID              uint            `json:"id" gorm:"not null"`
+	Code            string          `json:"code" gorm:"not null;size:255;unique"`
+	Type            string          `json:"type" gorm:"not null;size:255"`
+	Days            int             `json:"days" gorm:"not null"`
+	DiscountPercent int             `json:"discount_percent" gorm:"not null"`
+	PlanCode        string          `json:"plan_code" gorm:"size:255"`
+	MaxRedemptions  int             `json:"max_redemptions" gorm:"not null"`
+	PerUserLimit    int             `json:"per_user_limit" gorm:"not null"`
+	RedemptionCount int             `json:"redemption_count" gorm:"not null"`
+	ExpiresAt       *time.Time      `json:"expires_at"`
+	Campaign        string          `json:"campaign" gorm:"size:255;index"`
+	IsActive        bool            `json:"is_active" gorm:"not null"`
+	CreatedBy       string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy       string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy       *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt       *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt       *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt       *gorm.DeletedAt `json:"deleted_at"`
+}
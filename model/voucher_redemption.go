@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type VoucherRedemption struct {
+	ID        uint            `json:"id" gorm:"not null"`
+	VoucherID uint            `json:"voucher_id" gorm:"not null;index"`
+	AccountID uint            `json:"account_id" gorm:"not null;index"`
+	OrderCode string          `json:"order_code" gorm:"size:255;index"`
+	CreatedBy string          `json:"created_by" gorm:"size:255;default:SYSTEM"`
+	UpdatedBy string          `json:"updated_by" gorm:"size:255;default:SYSTEM"`
+	DeletedBy *string         `json:"deleted_by" gorm:"size:255"`
+	CreatedAt *time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt *time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+	DeletedAt *gorm.DeletedAt `json:"deleted_at"`
+}
@@ -0,0 +1,145 @@
+// Package actor carries the username acting on a request down to GORM,
+// whose callbacks write it to the CreatedBy, UpdatedBy and DeletedBy
+// columns. Queries have to run on a db bound to the request context
+// (db.WithContext) for the callbacks to see it; without a username the
+// columns keep their SYSTEM default.
+package actor
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type contextKey struct{}
+
+func WithUsername(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, contextKey{}, username)
+}
+
+// Username returns the acting username, empty when there is none.
+func Username(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	username, _ := ctx.Value(contextKey{}).(string)
+	return username
+}
+
+// RegisterCallbacks hooks the audit column callbacks into db.
+func RegisterCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("actor:create", setCreatedBy); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("actor:update", setUpdatedBy); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("actor:delete", setDeletedBy)
+}
+
+func setCreatedBy(db *gorm.DB) {
+	username := Username(db.Statement.Context)
+	if db.Error != nil || username == "" || db.Statement.Schema == nil {
+		return
+	}
+
+	fields := make([]*schema.Field, 0, 2)
+	for _, name := range []string{"CreatedBy", "UpdatedBy"} {
+		if field := db.Statement.Schema.LookUpField(name); field != nil {
+			fields = append(fields, field)
+		}
+	}
+	// values set by the caller win
+	fill := func(rv reflect.Value) {
+		for _, field := range fields {
+			if _, isZero := field.ValueOf(db.Statement.Context, rv); isZero {
+				db.AddError(field.Set(db.Statement.Context, rv, username))
+			}
+		}
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			fill(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		if rv.CanAddr() {
+			fill(rv)
+		}
+	}
+}
+
+func setUpdatedBy(db *gorm.DB) {
+	username := Username(db.Statement.Context)
+	if db.Error != nil || username == "" || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("UpdatedBy")
+	if field == nil {
+		return
+	}
+
+	switch db.Statement.Dest.(type) {
+	case map[string]interface{}, []map[string]interface{}:
+		db.Statement.SetColumn(field.DBName, username)
+	default:
+		if db.Statement.ReflectValue.Kind() != reflect.Struct || db.Statement.ReflectValue.CanAddr() {
+			db.Statement.SetColumn(field.DBName, username, true)
+		}
+	}
+}
+
+// A soft delete is an UPDATE that GORM builds with only deleted_at in its SET
+// clause, replacing any SET added beforehand. Build it here with deleted_by
+// as well; GORM then finds the SQL built and runs it as is.
+func setDeletedBy(db *gorm.DB) {
+	stmt := db.Statement
+	username := Username(stmt.Context)
+	if db.Error != nil || username == "" || stmt.Schema == nil || stmt.Unscoped || stmt.SQL.Len() > 0 {
+		return
+	}
+	deletedBy := stmt.Schema.LookUpField("DeletedBy")
+	if deletedBy == nil {
+		return
+	}
+	var softDelete *gorm.SoftDeleteDeleteClause
+	for _, c := range stmt.Schema.DeleteClauses {
+		if sd, ok := c.(gorm.SoftDeleteDeleteClause); ok {
+			softDelete = &sd
+		}
+	}
+	if softDelete == nil {
+		return
+	}
+
+	now := stmt.DB.NowFunc()
+	stmt.AddClause(clause.Set{
+		{Column: clause.Column{Name: softDelete.Field.DBName}, Value: now},
+		{Column: clause.Column{Name: deletedBy.DBName}, Value: username},
+	})
+	stmt.SetColumn(softDelete.Field.DBName, now, true)
+	stmt.SetColumn(deletedBy.DBName, &username, true)
+
+	// restrict to the primary keys of the value, as GORM does
+	_, queryValues := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+	column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+	if len(values) > 0 {
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+	}
+	if stmt.ReflectValue.CanAddr() && stmt.Dest != stmt.Model && stmt.Model != nil {
+		_, queryValues = schema.GetIdentityFieldValuesMap(stmt.Context, reflect.ValueOf(stmt.Model), stmt.Schema.PrimaryFields)
+		column, values = schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+		if len(values) > 0 {
+			stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+		}
+	}
+
+	gorm.SoftDeleteQueryClause(*softDelete).ModifyStatement(stmt)
+	stmt.AddClauseIfNotExists(clause.Update{})
+	stmt.Build(stmt.DB.Callback().Update().Clauses...)
+}
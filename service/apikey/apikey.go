@@ -0,0 +1,136 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"github.com/avarian/primbon-ajaib-backend/service/throttle"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// ScopePrimbonRead calls the primbon calculators
+	ScopePrimbonRead = "primbon:read"
+)
+
+// Scopes lists every scope a key can be granted.
+var Scopes = []string{
+	ScopePrimbonRead,
+}
+
+// Keys look like "pk_1a2b3c4d.<secret>"
+const prefixMark = "pk_"
+
+var (
+	ErrInvalidKey  = errors.New("invalid api key")
+	ErrExpiredKey  = errors.New("api key expired")
+	ErrRateLimited = errors.New("api key rate limit exceeded")
+)
+
+func IsScope(scope string) bool {
+	for _, v := range Scopes {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a new plain key, to be shown once, with the prefix and
+// secret hash to store.
+func Generate() (plain string, prefix string, secretHash string, err error) {
+	id := make([]byte, 4)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return
+	}
+	prefix = prefixMark + hex.EncodeToString(id)
+	plainSecret := base64.RawURLEncoding.EncodeToString(secret)
+	return prefix + "." + plainSecret, prefix, hash(plainSecret), nil
+}
+
+// Authenticator checks the keys presented by partners and applies their
+// per minute rate limit. Like session.Tracker, last used is written at most
+// once per interval.
+type Authenticator struct {
+	db           *gorm.DB
+	cache        cache.Store
+	throttle     *throttle.Throttle
+	defaultLimit int
+	interval     time.Duration
+}
+
+func NewAuthenticator(db *gorm.DB, store cache.Store, throttle *throttle.Throttle, defaultLimit int, interval time.Duration) *Authenticator {
+	return &Authenticator{
+		db:           db,
+		cache:        store,
+		throttle:     throttle,
+		defaultLimit: defaultLimit,
+		interval:     interval,
+	}
+}
+
+// Authenticate resolves a plain key to its record. A key over its rate
+// limit is returned with ErrRateLimited.
+func (a *Authenticator) Authenticate(ctx context.Context, plain string) (model.ApiKey, error) {
+	prefix, secret, ok := strings.Cut(plain, ".")
+	if !ok || !strings.HasPrefix(prefix, prefixMark) || secret == "" {
+		return model.ApiKey{}, ErrInvalidKey
+	}
+	key, result := repository.NewApiKeyRepository(a.db.WithContext(ctx)).OneByPrefix(prefix)
+	if result.Error != nil {
+		return model.ApiKey{}, result.Error
+	}
+	if result.RowsAffected == 0 || subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(key.SecretHash)) != 1 {
+		return model.ApiKey{}, ErrInvalidKey
+	}
+	if key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt) {
+		return key, ErrExpiredKey
+	}
+
+	limit := key.RateLimit
+	if limit <= 0 {
+		limit = a.defaultLimit
+	}
+	if limit > 0 {
+		// a cache outage lets requests through rather than lock partners out
+		allowed, err := a.throttle.Hit(ctx, "api_key:"+key.Prefix, limit, time.Minute)
+		if err != nil {
+			log.WithError(err).WithField("api_key", key.Prefix).Error("error throttle api key")
+		} else if !allowed {
+			return key, ErrRateLimited
+		}
+	}
+	if err := a.touch(ctx, key); err != nil {
+		log.WithError(err).WithField("api_key", key.Prefix).Error("error touch api key")
+	}
+	return key, nil
+}
+
+func (a *Authenticator) touch(ctx context.Context, key model.ApiKey) error {
+	hits, err := a.cache.IncrBy(ctx, "api_key:seen:"+strconv.Itoa(int(key.ID)), 1, a.interval)
+	if err != nil || hits > 1 {
+		return err
+	}
+	return repository.NewApiKeyRepository(a.db.WithContext(ctx)).TouchLastUsed(int(key.ID), time.Now()).Error
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	ActionAccountUpdate        = "ACCOUNT_UPDATE"
+	ActionAccountSuspend       = "ACCOUNT_SUSPEND"
+	ActionAccountUnsuspend     = "ACCOUNT_UNSUSPEND"
+	ActionAccountDelete        = "ACCOUNT_DELETE"
+	ActionAccountRestore       = "ACCOUNT_RESTORE"
+	ActionAccountPasswordReset = "ACCOUNT_PASSWORD_RESET"
+	ActionAccountUnlock        = "ACCOUNT_UNLOCK"
+	ActionAccountRoles         = "ACCOUNT_ROLES"
+	ActionAccountChatsRead     = "ACCOUNT_CHATS_READ"
+	ActionRolePermissions      = "ROLE_PERMISSIONS"
+	ActionLoginSuccess         = "LOGIN_SUCCESS"
+	ActionLoginFailure         = "LOGIN_FAILURE"
+	ActionPasswordChange       = "PASSWORD_CHANGE"
+	ActionTokenRevoke          = "TOKEN_REVOKE"
+	ActionPremiumGrant         = "PREMIUM_GRANT"
+	ActionPremiumRevoke        = "PREMIUM_REVOKE"
+	ActionAuditExport          = "AUDIT_EXPORT"
+	ActionImpersonationStart   = "IMPERSONATION_START"
+	ActionImpersonationEnd     = "IMPERSONATION_END"
+	ActionApiKeyCreate         = "API_KEY_CREATE"
+	ActionApiKeyUpdate         = "API_KEY_UPDATE"
+	ActionApiKeyRevoke         = "API_KEY_REVOKE"
+)
+
+// ActorSystem names events no signed in user caused, such as payment
+// notifications.
+const ActorSystem = "SYSTEM"
+
+// Change is the value of one field before and after an action.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+type Changes map[string]Change
+
+// Record appends an event. Pass the transaction of the change itself so
+// the event is only kept when the change is.
+func Record(db *gorm.DB, event model.AuditEvent, changes Changes) error {
+	if event.Actor == "" {
+		event.Actor = ActorSystem
+	}
+	if len(changes) > 0 {
+		raw, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		event.Changes = datatypes.JSON(raw)
+	}
+	_, result := repository.NewAuditEventRepository(db).Create(event)
+	return result.Error
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FallbackStore serves from the fallback store while the primary one is
+// failing, for state that must keep working when Redis is down. Values
+// written during an outage only live in the fallback.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+}
+
+func NewFallbackStore(primary Store, fallback Store) *FallbackStore {
+	return &FallbackStore{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (s *FallbackStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.primary.Get(ctx, key)
+	if err == nil || errors.Is(err, ErrMiss) {
+		return value, err
+	}
+	s.warn(err, key)
+	return s.fallback.Get(ctx, key)
+}
+
+func (s *FallbackStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if err := s.primary.Set(ctx, key, value, ttl); err != nil {
+		s.warn(err, key)
+		return s.fallback.Set(ctx, key, value, ttl)
+	}
+	return nil
+}
+
+func (s *FallbackStore) Delete(ctx context.Context, keys ...string) error {
+	// clear both so stale fallback values do not outlive the outage
+	err := s.primary.Delete(ctx, keys...)
+	if fallbackErr := s.fallback.Delete(ctx, keys...); err != nil {
+		s.warn(err, keys...)
+		return fallbackErr
+	}
+	return nil
+}
+
+func (s *FallbackStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	value, err := s.primary.IncrBy(ctx, key, n, ttl)
+	if err != nil {
+		s.warn(err, key)
+		return s.fallback.IncrBy(ctx, key, n, ttl)
+	}
+	return value, nil
+}
+
+func (s *FallbackStore) warn(err error, keys ...string) {
+	log.WithError(err).WithField("keys", keys).Warn("cache store failing, using fallback")
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrMiss = errors.New("cache miss")
+
+// Store is a small key/value abstraction backed by Redis in production and
+// by process memory when running without Redis.
+type Store interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// IncrBy atomically adds n to an integer key; ttl is applied when the key is created
+	IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+}
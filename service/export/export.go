@@ -0,0 +1,132 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+type chatbox struct {
+	Code      string     `json:"code"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+	Messages  []message  `json:"messages"`
+}
+
+// Build collects the personal data of an account into a ZIP archive: JSON
+// files of the account, its chatboxes with their messages, payments and
+// usage, plus a Markdown transcript per chatbox.
+func Build(db *gorm.DB, accountId uint) ([]byte, error) {
+	account, result := repository.NewAccountRepository(db).OneById(int(accountId))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("account %d not found", accountId)
+	}
+
+	chatboxes, result := repository.NewChatboxRepository(db).AllByAccountID(int(accountId))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	messageRepo := repository.NewChatboxMessageRepository(db)
+	exported := make([]chatbox, 0, len(chatboxes))
+	for _, v := range chatboxes {
+		messages, result := messageRepo.AllByChatboxCode(v.Code)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		item := chatbox{Code: v.Code, Name: v.Name, CreatedAt: v.CreatedAt, Messages: []message{}}
+		for _, m := range messages {
+			item.Messages = append(item.Messages, message{Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt})
+		}
+		exported = append(exported, item)
+	}
+
+	orders, result := repository.NewPaymentOrderRepository(db).HistoryByAccountID(int(accountId))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	usage, result := repository.NewUsageRecordRepository(db).AllByAccountID(int(accountId))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"account.json", account},
+		{"chatboxes.json", exported},
+		{"payment_orders.json", orders},
+		{"usage_records.json", usage},
+	}
+	for _, f := range files {
+		body, err := json.MarshalIndent(f.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(archive, f.name, body); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range exported {
+		if err := writeFile(archive, "transcripts/"+v.Code+".md", transcript(v)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFile(archive *zip.Writer, name string, body []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func transcript(c chatbox) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", c.Name)
+	if c.CreatedAt != nil {
+		fmt.Fprintf(&sb, "Dibuat %s\n\n", c.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	for _, m := range c.Messages {
+		at := ""
+		if m.CreatedAt != nil {
+			at = " (" + m.CreatedAt.Format("2006-01-02 15:04") + ")"
+		}
+		fmt.Fprintf(&sb, "**%s**%s\n\n%s\n\n", roleLabel(m.Role), at, m.Content)
+	}
+	return []byte(sb.String())
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "Kamu"
+	case "assistant":
+		return "Primbon Ajaib"
+	}
+	return role
+}
@@ -0,0 +1,189 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
+)
+
+// Key as configured. HS256 keys use Secret, RS256 and EdDSA keys read a PEM
+// encoded private key from PrivateKeyFile. Retired keys no longer verify.
+type Key struct {
+	ID             string `mapstructure:"kid"`
+	Algorithm      string `mapstructure:"algorithm"`
+	Secret         string `mapstructure:"secret"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	Retired        bool   `mapstructure:"retired"`
+}
+
+type entry struct {
+	key     Key
+	method  jwt.SigningMethod
+	signing interface{}
+	verify  interface{}
+}
+
+// Keyring signs tokens with the active key and verifies them with any key
+// that is not retired, so keys can be rotated without logging users out.
+type Keyring struct {
+	active  string
+	entries map[string]entry
+	order   []string
+}
+
+func NewKeyring(keys []Key, active string) (*Keyring, error) {
+	k := &Keyring{
+		active:  active,
+		entries: map[string]entry{},
+	}
+	for _, key := range keys {
+		e, err := load(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		if _, ok := k.entries[key.ID]; ok {
+			return nil, fmt.Errorf("key %s: duplicate kid", key.ID)
+		}
+		k.entries[key.ID] = e
+		k.order = append(k.order, key.ID)
+	}
+	signer, ok := k.entries[active]
+	if !ok || signer.key.Retired {
+		return nil, fmt.Errorf("active key %s: %w", active, ErrUnknownKey)
+	}
+	return k, nil
+}
+
+// Sign the claims with the active key, its kid goes in the token header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	signer := k.entries[k.active]
+	token := jwt.NewWithClaims(signer.method, claims)
+	token.Header["kid"] = signer.key.ID
+	return token.SignedString(signer.signing)
+}
+
+// Parse verifies the token against the key named by its kid. Tokens issued
+// before key ids were introduced carry none and are tried against every
+// HS256 key.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	kid, _ := peekKid(tokenString)
+	if kid != "" {
+		return jwt.ParseWithClaims(tokenString, claims, k.keyFunc(kid))
+	}
+
+	err := ErrUnknownKey
+	for _, id := range k.order {
+		if k.entries[id].method != jwt.SigningMethodHS256 {
+			continue
+		}
+		var token *jwt.Token
+		token, err = jwt.ParseWithClaims(tokenString, claims, k.keyFunc(id))
+		if err == nil {
+			return token, nil
+		}
+	}
+	return nil, err
+}
+
+func (k *Keyring) keyFunc(kid string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		e, ok := k.entries[kid]
+		if !ok || e.key.Retired {
+			return nil, ErrUnknownKey
+		}
+		// the algorithm is pinned by the key, never taken from the token
+		if token.Method.Alg() != e.method.Alg() {
+			return nil, ErrUnexpectedMethod
+		}
+		return e.verify, nil
+	}
+}
+
+// JWKS returns the public keys that verify tokens, as a JSON Web Key Set.
+// Shared secrets are never published.
+func (k *Keyring) JWKS() map[string]interface{} {
+	keys := []map[string]string{}
+	for _, id := range k.order {
+		e := k.entries[id]
+		if e.key.Retired {
+			continue
+		}
+		switch public := e.verify.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": e.method.Alg(),
+				"kid": id,
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": e.method.Alg(),
+				"kid": id,
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+func load(key Key) (entry, error) {
+	switch key.Algorithm {
+	case "", "HS256":
+		if key.Secret == "" {
+			return entry{}, errors.New("missing secret")
+		}
+		return entry{key: key, method: jwt.SigningMethodHS256, signing: []byte(key.Secret), verify: []byte(key.Secret)}, nil
+	case "RS256":
+		pem, err := os.ReadFile(key.PrivateKeyFile)
+		if err != nil {
+			return entry{}, err
+		}
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return entry{}, err
+		}
+		return entry{key: key, method: jwt.SigningMethodRS256, signing: private, verify: &private.PublicKey}, nil
+	case "EdDSA":
+		pem, err := os.ReadFile(key.PrivateKeyFile)
+		if err != nil {
+			return entry{}, err
+		}
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return entry{}, err
+		}
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return entry{}, jwt.ErrNotEdPrivateKey
+		}
+		return entry{key: key, method: jwt.SigningMethodEdDSA, signing: private, verify: private.Public()}, nil
+	}
+	return entry{}, fmt.Errorf("unsupported algorithm %s", key.Algorithm)
+}
+
+// Read the kid header without verifying the token
+func peekKid(tokenString string) (string, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return "", err
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid, nil
+}
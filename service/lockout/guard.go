@@ -0,0 +1,220 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+)
+
+// Limits for one kind of key, email or client IP.
+type Limits struct {
+	// Failures allowed in the window before delays start
+	FreeAttempts int
+	// Failures in the window that lock the key
+	LockoutThreshold int
+}
+
+type Config struct {
+	Window          time.Duration
+	Email           Limits
+	IP              Limits
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+}
+
+// Decision on whether a login attempt may go ahead.
+type Decision struct {
+	Allowed    bool
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+// Failure is the state after recording a failed attempt.
+type Failure struct {
+	EmailFailures int
+	IPFailures    int
+	// EmailLocked is set when this failure locked the email
+	EmailLocked bool
+	IPLocked    bool
+	Delay       time.Duration
+}
+
+// Guard counts failed logins per email and per client IP over a sliding
+// window. Past the free attempts every failure pushes back the next allowed
+// attempt with an exponential delay, and past the threshold the key is
+// locked for a while.
+type Guard struct {
+	cache  cache.Store
+	config Config
+}
+
+func NewGuard(store cache.Store, config Config) *Guard {
+	return &Guard{
+		cache:  store,
+		config: config,
+	}
+}
+
+func (g *Guard) LockoutDuration() time.Duration {
+	return g.config.LockoutDuration
+}
+
+func (g *Guard) Check(ctx context.Context, email string, ip string) (Decision, error) {
+	for _, key := range []string{emailKey(email), ipKey(ip)} {
+		for _, state := range []string{"locked", "next"} {
+			value, err := g.cache.Get(ctx, "lockout:"+state+":"+key)
+			if errors.Is(err, cache.ErrMiss) {
+				continue
+			}
+			if err != nil {
+				return Decision{}, err
+			}
+			until, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return Decision{}, err
+			}
+			if wait := time.Until(time.Unix(until, 0)); wait > 0 {
+				return Decision{Locked: state == "locked", RetryAfter: wait}, nil
+			}
+		}
+	}
+	return Decision{Allowed: true}, nil
+}
+
+func (g *Guard) Fail(ctx context.Context, email string, ip string) (Failure, error) {
+	var failure Failure
+	var err error
+
+	failure.EmailFailures, err = g.count(ctx, emailKey(email))
+	if err != nil {
+		return failure, err
+	}
+	failure.IPFailures, err = g.count(ctx, ipKey(ip))
+	if err != nil {
+		return failure, err
+	}
+
+	if failure.EmailLocked, err = g.lockIfOver(ctx, emailKey(email), failure.EmailFailures, g.config.Email); err != nil {
+		return failure, err
+	}
+	if failure.IPLocked, err = g.lockIfOver(ctx, ipKey(ip), failure.IPFailures, g.config.IP); err != nil {
+		return failure, err
+	}
+
+	emailDelay := g.delay(failure.EmailFailures, g.config.Email)
+	ipDelay := g.delay(failure.IPFailures, g.config.IP)
+	if emailDelay > 0 {
+		if err := g.hold(ctx, "next", emailKey(email), emailDelay); err != nil {
+			return failure, err
+		}
+	}
+	if ipDelay > 0 {
+		if err := g.hold(ctx, "next", ipKey(ip), ipDelay); err != nil {
+			return failure, err
+		}
+	}
+	failure.Delay = emailDelay
+	if ipDelay > failure.Delay {
+		failure.Delay = ipDelay
+	}
+	return failure, nil
+}
+
+// Succeed clears the failures of the email. The IP keeps its count, so one
+// good account does not let a client keep guessing others.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	return g.clear(ctx, emailKey(email))
+}
+
+// Unlock lifts a lockout of the email and forgets its failures.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	return g.clear(ctx, emailKey(email))
+}
+
+// Sliding window counter: the previous fixed window is weighted by how much
+// of it still overlaps the sliding window.
+func (g *Guard) count(ctx context.Context, key string) (int, error) {
+	now := time.Now()
+	window := int64(g.config.Window / time.Second)
+	index := now.Unix() / window
+	current, err := g.cache.IncrBy(ctx, bucketKey(key, index), 1, 2*g.config.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	previous := int64(0)
+	value, err := g.cache.Get(ctx, bucketKey(key, index-1))
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		return 0, err
+	}
+	if err == nil {
+		if previous, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, err
+		}
+	}
+
+	elapsed := float64(now.Unix()%window) / float64(window)
+	return int(current) + int(math.Floor(float64(previous)*(1-elapsed))), nil
+}
+
+func (g *Guard) delay(failures int, limits Limits) time.Duration {
+	over := failures - limits.FreeAttempts
+	if over <= 0 || g.config.BaseDelay <= 0 {
+		return 0
+	}
+	if over > 16 {
+		over = 16
+	}
+	delay := g.config.BaseDelay * time.Duration(1<<(over-1))
+	if g.config.MaxDelay > 0 && delay > g.config.MaxDelay {
+		delay = g.config.MaxDelay
+	}
+	return delay
+}
+
+// Lock the key once failures reach the threshold, reporting whether this
+// call locked it
+func (g *Guard) lockIfOver(ctx context.Context, key string, failures int, limits Limits) (bool, error) {
+	if limits.LockoutThreshold <= 0 || failures < limits.LockoutThreshold {
+		return false, nil
+	}
+	if _, err := g.cache.Get(ctx, "lockout:locked:"+key); err == nil {
+		return false, nil
+	} else if !errors.Is(err, cache.ErrMiss) {
+		return false, err
+	}
+	return true, g.hold(ctx, "locked", key, g.config.LockoutDuration)
+}
+
+func (g *Guard) hold(ctx context.Context, state string, key string, duration time.Duration) error {
+	until := strconv.FormatInt(time.Now().Add(duration).Unix(), 10)
+	return g.cache.Set(ctx, "lockout:"+state+":"+key, until, duration)
+}
+
+func (g *Guard) clear(ctx context.Context, key string) error {
+	index := time.Now().Unix() / int64(g.config.Window/time.Second)
+	return g.cache.Delete(ctx,
+		"lockout:locked:"+key,
+		"lockout:next:"+key,
+		bucketKey(key, index),
+		bucketKey(key, index-1),
+	)
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func bucketKey(key string, index int64) string {
+	return "lockout:fail:" + key + ":" + strconv.FormatInt(index, 10)
+}
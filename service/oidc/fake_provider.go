@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+const fakeKeyID = "fake-idp"
+
+type fakeGrant struct {
+	RedirectURI   string
+	Challenge     string
+	Nonce         string
+	Email         string
+	EmailVerified bool
+	Name          string
+	ExpiresAt     time.Time
+}
+
+// FakeProvider is a local stand-in OpenID provider built on httptest. Its
+// sign in page lets anyone pick the email and name to log in with, and it
+// checks PKCE and client credentials like a real provider, so the whole
+// social login flow can be exercised without network.
+type FakeProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	mu           sync.Mutex
+	grants       map[string]*fakeGrant
+}
+
+// NewFakeProvider makes a provider with a fresh signing key. An empty issuer
+// is taken from the server address once started.
+func NewFakeProvider(issuer string, clientID string, clientSecret string) (*FakeProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &FakeProvider{
+		issuer:       strings.TrimRight(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		grants:       map[string]*fakeGrant{},
+	}, nil
+}
+
+// Start serves the provider on listenAddress, or on a random local port
+// when it is empty. Close the returned server to stop it.
+func (p *FakeProvider) Start(listenAddress string) (*httptest.Server, error) {
+	server := httptest.NewUnstartedServer(p)
+	if listenAddress != "" {
+		listener, err := net.Listen("tcp", listenAddress)
+		if err != nil {
+			return nil, err
+		}
+		server.Listener.Close()
+		server.Listener = listener
+	}
+	server.Start()
+	if p.issuer == "" {
+		p.issuer = server.URL
+	}
+	return server, nil
+}
+
+func (p *FakeProvider) Issuer() string {
+	return p.issuer
+}
+
+func (p *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, discovery{
+			Issuer:                p.issuer,
+			AuthorizationEndpoint: p.issuer + "/authorize",
+			TokenEndpoint:         p.issuer + "/token",
+			JwksURI:               p.issuer + "/jwks",
+		})
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": jwt.SigningMethodRS256.Alg(),
+				"kid": fakeKeyID,
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.PublicKey.E)).Bytes()),
+			}},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var fakeSignInPage = template.Must(template.New("sign-in").Parse(`<!DOCTYPE html>
+<html><body>
+<h1>Fake sign in</h1>
+<form method="post">
+{{range $k, $v := .}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}<p><label>Email <input name="email" value="user@example.com"></label></p>
+<p><label>Name <input name="name" value="Fake User"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+<button>Sign in</button>
+</form>
+</body></html>`))
+
+// GET /authorize renders the sign in page, POST /authorize with the same
+// query plus "email", "name" and "email_verified" form values approves it.
+func (p *FakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redirectURI := r.Form.Get("redirect_uri")
+	if r.Form.Get("client_id") != p.clientID || redirectURI == "" || r.Form.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("code_challenge") == "" || r.Form.Get("code_challenge_method") != "S256" {
+		http.Error(w, "pkce with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fakeSignInPage.Execute(w, r.URL.Query())
+		return
+	}
+
+	code := uuid.New().String()
+	p.mu.Lock()
+	p.grants[code] = &fakeGrant{
+		RedirectURI:   redirectURI,
+		Challenge:     r.Form.Get("code_challenge"),
+		Nonce:         r.Form.Get("nonce"),
+		Email:         r.PostForm.Get("email"),
+		EmailVerified: r.PostForm.Get("email_verified") == "true",
+		Name:          r.PostForm.Get("name"),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	query := url.Values{"code": {code}, "state": {r.Form.Get("state")}}
+	http.Redirect(w, r, redirectURI+"?"+query.Encode(), http.StatusFound)
+}
+
+// POST /token redeems a code once, checking the client, the redirect uri
+// and the PKCE verifier.
+func (p *FakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || clientSecret != p.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	grant, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(grant.ExpiresAt) || grant.RedirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.Challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code verifier mismatch"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.issuer,
+		"aud":            p.clientID,
+		"sub":            subjectOf(grant.Email),
+		"email":          grant.Email,
+		"email_verified": grant.EmailVerified,
+		"name":           grant.Name,
+		"nonce":          grant.Nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	})
+	token.Header["kid"] = fakeKeyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": uuid.New().String(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// The same email always gets the same subject, like a real account would
+func subjectOf(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
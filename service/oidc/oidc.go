@@ -0,0 +1,361 @@
+// Package oidc signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE. The state, nonce and code verifier of a
+// pending login are kept in the cache store; the ID token is verified
+// against the keys the provider publishes.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown login provider")
+	ErrInvalidState    = errors.New("invalid or expired login state")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+)
+
+// Unknown key ids refetch the provider keys at most this often
+const jwksRefreshInterval = time.Minute
+
+type ProviderConfig struct {
+	Name         string   `mapstructure:"name"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
+// Identity is what the provider vouches for about the user.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type provider struct {
+	config ProviderConfig
+
+	mu          sync.Mutex
+	discovery   *discovery
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+type pendingLogin struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// Login runs the sign in flow against the configured providers.
+type Login struct {
+	providers  map[string]*provider
+	cache      cache.Store
+	stateTTL   time.Duration
+	httpClient *http.Client
+}
+
+func NewLogin(configs []ProviderConfig, store cache.Store, stateTTL time.Duration) *Login {
+	providers := map[string]*provider{}
+	for _, v := range configs {
+		providers[v.Name] = &provider{config: v}
+	}
+	return &Login{
+		providers:  providers,
+		cache:      store,
+		stateTTL:   stateTTL,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (l *Login) StateTTL() time.Duration {
+	return l.stateTTL
+}
+
+// AuthURL starts a login and returns the provider page to send the user to,
+// with the state the callback will carry. The caller binds the state to the
+// browser, so a callback sent to someone else can not sign them in.
+func (l *Login) AuthURL(ctx context.Context, providerName string) (authURL string, state string, err error) {
+	p, ok := l.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	d, err := l.discover(ctx, p)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = randomToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	pending, err := json.Marshal(pendingLogin{Provider: providerName, Nonce: nonce, Verifier: verifier})
+	if err != nil {
+		return "", "", err
+	}
+	if err := l.cache.Set(ctx, stateKey(state), string(pending), l.stateTTL); err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// Exchange finishes a login: the state is consumed, the code redeemed with
+// the PKCE verifier and the returned ID token verified.
+func (l *Login) Exchange(ctx context.Context, providerName string, code string, state string) (Identity, error) {
+	p, ok := l.providers[providerName]
+	if !ok {
+		return Identity{}, ErrUnknownProvider
+	}
+	if state == "" || code == "" {
+		return Identity{}, ErrInvalidState
+	}
+
+	raw, err := l.cache.Get(ctx, stateKey(state))
+	if errors.Is(err, cache.ErrMiss) {
+		return Identity{}, ErrInvalidState
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	// a state is good for one callback only
+	if err := l.cache.Delete(ctx, stateKey(state)); err != nil {
+		return Identity{}, err
+	}
+	var pending pendingLogin
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil || pending.Provider != providerName {
+		return Identity{}, ErrInvalidState
+	}
+
+	d, err := l.discover(ctx, p)
+	if err != nil {
+		return Identity{}, err
+	}
+	idToken, err := l.redeem(ctx, p, d, code, pending.Verifier)
+	if err != nil {
+		return Identity{}, err
+	}
+	return l.verify(ctx, p, d, idToken, pending.Nonce)
+}
+
+func (l *Login) redeem(ctx context.Context, p *provider, d *discovery, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	return body.IDToken, nil
+}
+
+func (l *Login) verify(ctx context.Context, p *provider, d *discovery, idToken string, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return l.key(ctx, p, d, kid)
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if !claims.VerifyIssuer(d.Issuer, true) || !claims.VerifyAudience(p.config.ClientID, true) {
+		return Identity{}, fmt.Errorf("%w: issuer or audience mismatch", ErrInvalidIDToken)
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Identity{}, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	identity := Identity{Provider: p.config.Name}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// some providers send the flag as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if identity.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return identity, nil
+}
+
+// Provider metadata is fetched once and kept for the life of the process.
+func (l *Login) discover(ctx context.Context, p *provider) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := l.getJSON(ctx, strings.TrimRight(p.config.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("provider %s announces issuer %s", p.config.Name, d.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// Keys are refetched when a token names one we do not know, which is how
+// providers roll their keys.
+func (l *Login) key(ctx context.Context, p *provider, d *discovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := l.getJSON(ctx, d.JwksURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, v := range jwks.Keys {
+		if v.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(v.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(v.E)
+		if err != nil {
+			continue
+		}
+		keys[v.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (l *Login) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func stateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
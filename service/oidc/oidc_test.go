@@ -0,0 +1,249 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testClientID     = "primbon-ajaib"
+	testClientSecret = "test-secret"
+	testRedirectURL  = "http://app.test/login/oidc/fake/callback"
+)
+
+type testIdP struct {
+	fake   *FakeProvider
+	server *httptest.Server
+	login  *Login
+	store  *cache.MemoryStore
+}
+
+// Start a fake provider on a random port with a login that knows it as
+// "fake", plus "other" pointing at the same provider.
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	fake, err := NewFakeProvider("", testClientID, testClientSecret)
+	if err != nil {
+		t.Fatalf("new fake provider: %v", err)
+	}
+	server, err := fake.Start("")
+	if err != nil {
+		t.Fatalf("start fake provider: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	store := cache.NewMemoryStore()
+	configs := []ProviderConfig{}
+	for _, name := range []string{"fake", "other"} {
+		configs = append(configs, ProviderConfig{
+			Name:         name,
+			Issuer:       fake.Issuer(),
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  testRedirectURL,
+		})
+	}
+	return &testIdP{fake: fake, server: server, login: NewLogin(configs, store, time.Minute), store: store}
+}
+
+// Sign in on the provider page and return the code and state it sends back
+// to the app. tamper may change the authorization request first.
+func (idp *testIdP) authorize(t *testing.T, provider string, email string, tamper func(url.Values)) (string, string) {
+	t.Helper()
+	authURL, state, err := idp.login.AuthURL(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("auth url without PKCE S256: %s", authURL)
+	}
+	if query.Get("state") != state {
+		t.Fatalf("auth url state = %q, want %q", query.Get("state"), state)
+	}
+	if tamper != nil {
+		tamper(query)
+	}
+	parsed.RawQuery = query.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(parsed.String(), url.Values{
+		"email":          {email},
+		"name":           {"Budi"},
+		"email_verified": {"true"},
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want 302", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse callback: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestExchange(t *testing.T) {
+	idp := newTestIdP(t)
+	code, state := idp.authorize(t, "fake", "budi@example.com", nil)
+
+	identity, err := idp.login.Exchange(context.Background(), "fake", code, state)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if identity.Provider != "fake" || identity.Email != "budi@example.com" || !identity.EmailVerified || identity.Name != "Budi" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+	if identity.Subject != subjectOf("budi@example.com") {
+		t.Errorf("subject = %q, want %q", identity.Subject, subjectOf("budi@example.com"))
+	}
+}
+
+func TestExchangeReplayedState(t *testing.T) {
+	idp := newTestIdP(t)
+	code, state := idp.authorize(t, "fake", "budi@example.com", nil)
+	if _, err := idp.login.Exchange(context.Background(), "fake", code, state); err != nil {
+		t.Fatalf("first exchange: %v", err)
+	}
+
+	_, err := idp.login.Exchange(context.Background(), "fake", code, state)
+	if !errors.Is(err, ErrInvalidState) {
+		t.Errorf("replayed state: err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestExchangeUnknownState(t *testing.T) {
+	idp := newTestIdP(t)
+	code, _ := idp.authorize(t, "fake", "budi@example.com", nil)
+
+	for _, state := range []string{"", "made-up-state"} {
+		_, err := idp.login.Exchange(context.Background(), "fake", code, state)
+		if !errors.Is(err, ErrInvalidState) {
+			t.Errorf("state %q: err = %v, want ErrInvalidState", state, err)
+		}
+	}
+}
+
+func TestExchangeProviderMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	code, state := idp.authorize(t, "fake", "budi@example.com", nil)
+
+	_, err := idp.login.Exchange(context.Background(), "other", code, state)
+	if !errors.Is(err, ErrInvalidState) {
+		t.Errorf("err = %v, want ErrInvalidState", err)
+	}
+	_, err = idp.login.Exchange(context.Background(), "missing", code, state)
+	if !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("err = %v, want ErrUnknownProvider", err)
+	}
+	if _, _, err := idp.login.AuthURL(context.Background(), "missing"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("auth url err = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestExchangePKCEMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	// a code stolen from a login started with another verifier
+	code, state := idp.authorize(t, "fake", "budi@example.com", func(query url.Values) {
+		query.Set("code_challenge", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM")
+	})
+
+	_, err := idp.login.Exchange(context.Background(), "fake", code, state)
+	if err == nil {
+		t.Fatal("exchange with a mismatched code verifier succeeded")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	code, state := idp.authorize(t, "fake", "budi@example.com", func(query url.Values) {
+		query.Set("nonce", "replayed-nonce")
+	})
+
+	_, err := idp.login.Exchange(context.Background(), "fake", code, state)
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyRejectsForgedTokens(t *testing.T) {
+	idp := newTestIdP(t)
+	ctx := context.Background()
+	p := idp.login.providers["fake"]
+	d, err := idp.login.discover(ctx, p)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":            idp.fake.Issuer(),
+			"aud":            testClientID,
+			"sub":            "subject-1",
+			"email":          "budi@example.com",
+			"email_verified": true,
+			"nonce":          "nonce-1",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, c jwt.MapClaims, key interface{}) string {
+		token := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return signed
+	}
+
+	valid := sign(jwt.SigningMethodRS256, fakeKeyID, claims(nil), idp.fake.key)
+	if _, err := idp.login.verify(ctx, p, d, valid, "nonce-1"); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	cases := map[string]string{
+		"unknown kid":   sign(jwt.SigningMethodRS256, "rotated-away", claims(nil), idp.fake.key),
+		"bad signature": sign(jwt.SigningMethodRS256, fakeKeyID, claims(nil), otherKey),
+		"hs256":         sign(jwt.SigningMethodHS256, fakeKeyID, claims(nil), []byte(testClientSecret)),
+		"alg none":      sign(jwt.SigningMethodNone, fakeKeyID, claims(nil), jwt.UnsafeAllowNoneSignatureType),
+		"wrong aud":     sign(jwt.SigningMethodRS256, fakeKeyID, claims(func(c jwt.MapClaims) { c["aud"] = "someone-else" }), idp.fake.key),
+		"wrong iss":     sign(jwt.SigningMethodRS256, fakeKeyID, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.test" }), idp.fake.key),
+		"expired":       sign(jwt.SigningMethodRS256, fakeKeyID, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }), idp.fake.key),
+		"wrong nonce":   sign(jwt.SigningMethodRS256, fakeKeyID, claims(func(c jwt.MapClaims) { c["nonce"] = "nonce-2" }), idp.fake.key),
+		"no subject":    sign(jwt.SigningMethodRS256, fakeKeyID, claims(func(c jwt.MapClaims) { delete(c, "sub") }), idp.fake.key),
+	}
+	for name, token := range cases {
+		if _, err := idp.login.verify(ctx, p, d, token, "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidIDToken", name, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/util"
+)
+
+var (
+	ErrCooldown        = errors.New("otp recently sent, wait before requesting again")
+	ErrInvalidCode     = errors.New("invalid or expired otp")
+	ErrTooManyAttempts = errors.New("too many attempts, request a new otp")
+	ErrTooManyIssues   = errors.New("too many otp requested, try again later")
+)
+
+const (
+	PurposeLogin       = "login"
+	PurposeVerifyPhone = "verify_phone"
+	PurposeChangePhone = "change_phone"
+)
+
+type Config struct {
+	// Key for the HMAC used to hash codes at rest
+	Secret      string
+	Length      int
+	TTL         time.Duration
+	MaxAttempts int
+	Cooldown    time.Duration
+	// Codes sent to one phone number per IssueWindow. Every code comes with
+	// fresh attempts, so this is what bounds the guesses over time.
+	MaxIssues   int
+	IssueWindow time.Duration
+}
+
+// OTP issues numeric one-time codes delivered by SMS. Codes are kept hashed
+// in the cache store with a TTL, and both verification attempts and resends
+// are limited.
+type OTP struct {
+	cache     cache.Store
+	messenger util.Messenger
+	config    Config
+}
+
+func NewOTP(store cache.Store, messenger util.Messenger, config Config) *OTP {
+	return &OTP{
+		cache:     store,
+		messenger: messenger,
+		config:    config,
+	}
+}
+
+// IssueLimit returns the codes sent to one phone number per window, for
+// callers that throttle requests before they know whether a code is sent.
+func (o *OTP) IssueLimit() (int, time.Duration) {
+	return o.config.MaxIssues, o.config.IssueWindow
+}
+
+func (o *OTP) Issue(ctx context.Context, purpose string, phoneNumber string) error {
+	if _, err := o.cache.Get(ctx, cooldownKey(purpose, phoneNumber)); err == nil {
+		return ErrCooldown
+	} else if !errors.Is(err, cache.ErrMiss) {
+		return err
+	}
+	if o.config.MaxIssues > 0 {
+		issues, err := o.cache.IncrBy(ctx, issuesKey(purpose, phoneNumber), 1, o.config.IssueWindow)
+		if err != nil {
+			return err
+		}
+		if int(issues) > o.config.MaxIssues {
+			return ErrTooManyIssues
+		}
+	}
+
+	code, err := generateCode(o.config.Length)
+	if err != nil {
+		return err
+	}
+
+	if err := o.cache.Set(ctx, codeKey(purpose, phoneNumber), o.hash(phoneNumber, code), o.config.TTL); err != nil {
+		return err
+	}
+	if err := o.cache.Delete(ctx, attemptsKey(purpose, phoneNumber)); err != nil {
+		return err
+	}
+	if err := o.cache.Set(ctx, cooldownKey(purpose, phoneNumber), "1", o.config.Cooldown); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Kode OTP Primbon Ajaib kamu: %s. Berlaku %d menit, jangan berikan kepada siapa pun.",
+		code, int(o.config.TTL.Minutes()))
+	return o.messenger.SendSMS(phoneNumber, text)
+}
+
+// Verify checks the code and consumes it on success. Every call counts as an
+// attempt, the code is dropped once MaxAttempts is exceeded.
+func (o *OTP) Verify(ctx context.Context, purpose string, phoneNumber string, code string) error {
+	attempts, err := o.cache.IncrBy(ctx, attemptsKey(purpose, phoneNumber), 1, o.config.TTL)
+	if err != nil {
+		return err
+	}
+	if int(attempts) > o.config.MaxAttempts {
+		o.cache.Delete(ctx, codeKey(purpose, phoneNumber))
+		return ErrTooManyAttempts
+	}
+
+	expected, err := o.cache.Get(ctx, codeKey(purpose, phoneNumber))
+	if errors.Is(err, cache.ErrMiss) {
+		return ErrInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(o.hash(phoneNumber, code))) {
+		return ErrInvalidCode
+	}
+
+	return o.cache.Delete(ctx, codeKey(purpose, phoneNumber), attemptsKey(purpose, phoneNumber))
+}
+
+func (o *OTP) hash(phoneNumber string, code string) string {
+	mac := hmac.New(sha256.New, []byte(o.config.Secret))
+	mac.Write([]byte(phoneNumber + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateCode(length int) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(byte('0' + n.Int64()))
+	}
+	return sb.String(), nil
+}
+
+func codeKey(purpose string, phoneNumber string) string {
+	return "otp:code:" + purpose + ":" + phoneNumber
+}
+
+func attemptsKey(purpose string, phoneNumber string) string {
+	return "otp:attempts:" + purpose + ":" + phoneNumber
+}
+
+func issuesKey(purpose string, phoneNumber string) string {
+	return "otp:issues:" + purpose + ":" + phoneNumber
+}
+
+func cooldownKey(purpose string, phoneNumber string) string {
+	return "otp:cooldown:" + purpose + ":" + phoneNumber
+}
@@ -0,0 +1,167 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type fakeTransaction struct {
+	OrderId     string
+	GrossAmount int64
+	Status      string
+}
+
+// FakeGateway is a local stand-in for the Midtrans Snap API. It accepts
+// transactions, serves a payment page and posts signed notifications to
+// notifyUrl, so the whole checkout flow can be exercised without network.
+type FakeGateway struct {
+	serverKey    string
+	baseUrl      string
+	notifyUrl    string
+	httpClient   *http.Client
+	mu           sync.Mutex
+	transactions map[string]*fakeTransaction
+}
+
+func NewFakeGateway(serverKey string, baseUrl string, notifyUrl string) *FakeGateway {
+	return &FakeGateway{
+		serverKey:    serverKey,
+		baseUrl:      strings.TrimRight(baseUrl, "/"),
+		notifyUrl:    notifyUrl,
+		httpClient:   &http.Client{Timeout: 15 * time.Second},
+		transactions: map[string]*fakeTransaction{},
+	}
+}
+
+func (g *FakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/snap/v1/transactions":
+		g.createTransaction(w, r)
+	case strings.HasPrefix(r.URL.Path, "/pay/"):
+		g.pay(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (g *FakeGateway) createTransaction(w http.ResponseWriter, r *http.Request) {
+	if key, _, ok := r.BasicAuth(); !ok || key != g.serverKey {
+		writeJSON(w, http.StatusUnauthorized, mtResponse{ErrorMessages: []string{"unauthorized"}})
+		return
+	}
+
+	var req mtRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, mtResponse{ErrorMessages: []string{err.Error()}})
+		return
+	}
+
+	token := uuid.New().String()
+	g.mu.Lock()
+	g.transactions[token] = &fakeTransaction{
+		OrderId:     req.TransactionDetails.OrderId,
+		GrossAmount: req.TransactionDetails.GrossAmount,
+		Status:      "pending",
+	}
+	g.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, mtResponse{
+		Token:       token,
+		RedirectUrl: g.baseUrl + "/pay/" + token,
+	})
+}
+
+var fakePayPage = template.Must(template.New("pay").Parse(`<!DOCTYPE html>
+<html><body>
+<h1>Fake payment</h1>
+<p>Order {{.OrderId}} &mdash; Rp {{.GrossAmount}} &mdash; {{.Status}}</p>
+<form method="post"><button name="status" value="settlement">Pay</button></form>
+<form method="post"><button name="status" value="expire">Expire</button></form>
+<form method="post"><button name="status" value="refund">Refund</button></form>
+</body></html>`))
+
+// GET /pay/:token renders the payment page, POST /pay/:token with a
+// "status" form value settles, expires or refunds the transaction.
+func (g *FakeGateway) pay(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/pay/")
+
+	g.mu.Lock()
+	trx, ok := g.transactions[token]
+	g.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		status := r.FormValue("status")
+		if err := g.notify(token, trx, status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		g.mu.Lock()
+		trx.Status = status
+		g.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fakePayPage.Execute(w, trx)
+}
+
+func (g *FakeGateway) notify(token string, trx *fakeTransaction, status string) error {
+	statusCode := "200"
+	if status == "expire" {
+		statusCode = "407"
+	}
+	grossAmount := strconv.FormatInt(trx.GrossAmount, 10) + ".00"
+
+	body, err := json.Marshal(&mtNotification{
+		OrderId:           trx.OrderId,
+		StatusCode:        statusCode,
+		GrossAmount:       grossAmount,
+		SignatureKey:      midtransSignature(trx.OrderId, statusCode, grossAmount, g.serverKey),
+		TransactionId:     token,
+		TransactionStatus: status,
+		FraudStatus:       "accept",
+	})
+	if err != nil {
+		return err
+	}
+
+	logCtx := log.WithFields(log.Fields{
+		"order":  trx.OrderId,
+		"status": status,
+		"url":    g.notifyUrl,
+	})
+
+	resp, err := g.httpClient.Post(g.notifyUrl, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		logCtx.Error(err.Error())
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logCtx.WithField("statusCode", resp.StatusCode).Error("notification rejected")
+		return fmt.Errorf("notification rejected with status %d", resp.StatusCode)
+	}
+
+	logCtx.Info("notification sent")
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
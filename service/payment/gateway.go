@@ -0,0 +1,30 @@
+package payment
+
+import (
+	"errors"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+)
+
+var ErrInvalidSignature = errors.New("invalid notification signature")
+
+// Transaction is what the gateway returns after an order is registered.
+type Transaction struct {
+	Reference  string
+	PaymentUrl string
+}
+
+// Notification is a verified webhook payload, with the provider status
+// already mapped to one of the model.PaymentOrderStatus* values.
+type Notification struct {
+	OrderCode   string
+	Reference   string
+	Status      string
+	GrossAmount int64
+}
+
+type Gateway interface {
+	Name() string
+	CreateTransaction(order model.PaymentOrder, account model.Account) (Transaction, error)
+	ParseNotification(body []byte) (Notification, error)
+}
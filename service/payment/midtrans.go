@@ -0,0 +1,182 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	log "github.com/sirupsen/logrus"
+)
+
+type mtTransactionDetails struct {
+	OrderId     string `json:"order_id"`
+	GrossAmount int64  `json:"gross_amount"`
+}
+
+type mtCustomerDetails struct {
+	FirstName string `json:"first_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+}
+
+type mtRequest struct {
+	TransactionDetails mtTransactionDetails `json:"transaction_details"`
+	CustomerDetails    mtCustomerDetails    `json:"customer_details"`
+}
+
+type mtResponse struct {
+	Token         string   `json:"token"`
+	RedirectUrl   string   `json:"redirect_url"`
+	ErrorMessages []string `json:"error_messages,omitempty"`
+}
+
+type mtNotification struct {
+	OrderId           string `json:"order_id"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	SignatureKey      string `json:"signature_key"`
+	TransactionId     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	FraudStatus       string `json:"fraud_status"`
+}
+
+// MidtransGateway talks to the Midtrans Snap API. The base url is
+// configurable so it can point at the sandbox, production or FakeGateway.
+type MidtransGateway struct {
+	serverKey  string
+	baseUrl    string
+	httpClient *http.Client
+}
+
+func NewMidtransGateway(serverKey string, baseUrl string) *MidtransGateway {
+	return &MidtransGateway{
+		serverKey: serverKey,
+		baseUrl:   strings.TrimRight(baseUrl, "/"),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (g *MidtransGateway) Name() string { return "midtrans" }
+
+func (g *MidtransGateway) CreateTransaction(order model.PaymentOrder, account model.Account) (Transaction, error) {
+	apiUrl := g.baseUrl + "/snap/v1/transactions"
+	logCtx := log.WithFields(log.Fields{
+		"order": order.Code,
+		"url":   apiUrl,
+	})
+
+	body, err := json.Marshal(&mtRequest{
+		TransactionDetails: mtTransactionDetails{
+			OrderId:     order.Code,
+			GrossAmount: order.Amount,
+		},
+		CustomerDetails: mtCustomerDetails{
+			FirstName: account.Name,
+			Email:     account.Email,
+			Phone:     account.Phone(),
+		},
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(body))
+	if err != nil {
+		logCtx.Error(err.Error())
+		return Transaction{}, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+	req.SetBasicAuth(g.serverKey, "")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		logCtx.Error(err.Error())
+		return Transaction{}, err
+	}
+	defer resp.Body.Close()
+
+	var result mtResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logCtx.Error(err.Error())
+		return Transaction{}, err
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		logCtx.WithFields(log.Fields{
+			"statusCode": resp.StatusCode,
+			"errors":     result.ErrorMessages,
+		}).Error("transaction not created")
+		return Transaction{}, fmt.Errorf("midtrans: %s", strings.Join(result.ErrorMessages, ", "))
+	}
+
+	logCtx.WithField("statusCode", resp.StatusCode).Info("transaction created")
+	return Transaction{
+		Reference:  result.Token,
+		PaymentUrl: result.RedirectUrl,
+	}, nil
+}
+
+func (g *MidtransGateway) ParseNotification(body []byte) (Notification, error) {
+	var n mtNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return Notification{}, err
+	}
+
+	expected := midtransSignature(n.OrderId, n.StatusCode, n.GrossAmount, g.serverKey)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(n.SignatureKey)) != 1 {
+		return Notification{}, ErrInvalidSignature
+	}
+
+	amount, err := strconv.ParseFloat(n.GrossAmount, 64)
+	if err != nil {
+		return Notification{}, errors.New("invalid gross_amount")
+	}
+
+	return Notification{
+		OrderCode:   n.OrderId,
+		Reference:   n.TransactionId,
+		Status:      midtransStatus(n.TransactionStatus, n.FraudStatus),
+		GrossAmount: int64(amount),
+	}, nil
+}
+
+func midtransSignature(orderId string, statusCode string, grossAmount string, serverKey string) string {
+	sum := sha512.Sum512([]byte(orderId + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func midtransStatus(transactionStatus string, fraudStatus string) string {
+	switch transactionStatus {
+	case "capture":
+		if fraudStatus == "challenge" {
+			return model.PaymentOrderStatusPending
+		}
+		return model.PaymentOrderStatusPaid
+	case "settlement":
+		return model.PaymentOrderStatusPaid
+	case "deny", "cancel", "expire", "failure":
+		return model.PaymentOrderStatusExpired
+	case "refund":
+		return model.PaymentOrderStatusRefunded
+	case "partial_refund":
+		// the order stays paid, a partial refund takes no premium days back
+		return model.PaymentOrderStatusPaid
+	}
+	return model.PaymentOrderStatusPending
+}
@@ -0,0 +1,158 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMessageQuotaExceeded = errors.New("daily message quota exceeded")
+	ErrChatboxQuotaExceeded = errors.New("chatbox quota exceeded")
+)
+
+// Limits of a plan. A zero limit means unlimited.
+type Limits struct {
+	PlanCode       string `json:"plan_code"`
+	MessagesPerDay int    `json:"messages_per_day"`
+	MaxChatboxes   int    `json:"max_chatboxes"`
+}
+
+// Usage is the current consumption against Limits, Remaining is -1 when unlimited.
+type Usage struct {
+	Limits
+	MessagesUsed       int       `json:"messages_used"`
+	MessagesRemaining  int       `json:"messages_remaining"`
+	ChatboxesUsed      int       `json:"chatboxes_used"`
+	ChatboxesRemaining int       `json:"chatboxes_remaining"`
+	ResetAt            time.Time `json:"reset_at"`
+}
+
+type Quota struct {
+	db    *gorm.DB
+	cache cache.Store
+	free  Limits
+}
+
+func NewQuota(db *gorm.DB, store cache.Store, free Limits) *Quota {
+	return &Quota{
+		db:    db,
+		cache: store,
+		free:  free,
+	}
+}
+
+// LimitsFor returns the limits of the account's plan while premium is valid,
+// otherwise the free tier limits.
+func (q *Quota) LimitsFor(account model.Account) (Limits, error) {
+	if account.PlanCode == "" || !time.Now().Before(time.Time(account.ValidUntil)) {
+		return q.free, nil
+	}
+
+	planRepo := repository.NewPlanRepository(q.db)
+	plan, result := planRepo.OneByCode(account.PlanCode)
+	if result.Error != nil {
+		return Limits{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return q.free, nil
+	}
+
+	return Limits{
+		PlanCode:       plan.Code,
+		MessagesPerDay: plan.MessagesPerDay,
+		MaxChatboxes:   plan.MaxChatboxes,
+	}, nil
+}
+
+func (q *Quota) Usage(ctx context.Context, account model.Account, limits Limits) (Usage, error) {
+	usage := Usage{Limits: limits, ResetAt: nextReset()}
+
+	used, err := q.cache.Get(ctx, messageKey(account))
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		return usage, err
+	}
+	usage.MessagesUsed, _ = strconv.Atoi(used)
+	usage.MessagesRemaining = remaining(limits.MessagesPerDay, usage.MessagesUsed)
+
+	chatboxRepo := repository.NewChatboxRepository(q.db)
+	count, result := chatboxRepo.CountByAccountID(int(account.ID))
+	if result.Error != nil {
+		return usage, result.Error
+	}
+	usage.ChatboxesUsed = int(count)
+	usage.ChatboxesRemaining = remaining(limits.MaxChatboxes, usage.ChatboxesUsed)
+
+	return usage, nil
+}
+
+// ConsumeMessage atomically takes one message from today's quota and returns
+// the remaining messages. Nothing is consumed when the quota is exhausted.
+func (q *Quota) ConsumeMessage(ctx context.Context, account model.Account, limits Limits) (int, error) {
+	used, err := q.cache.IncrBy(ctx, messageKey(account), 1, time.Until(nextReset()))
+	if err != nil {
+		return 0, err
+	}
+	if limits.MessagesPerDay > 0 && int(used) > limits.MessagesPerDay {
+		q.cache.IncrBy(ctx, messageKey(account), -1, 0)
+		return 0, ErrMessageQuotaExceeded
+	}
+	return remaining(limits.MessagesPerDay, int(used)), nil
+}
+
+// RefundMessage gives back a message consumed for a request that failed.
+func (q *Quota) RefundMessage(ctx context.Context, account model.Account) error {
+	_, err := q.cache.IncrBy(ctx, messageKey(account), -1, 0)
+	return err
+}
+
+// CreateChatbox creates a chatbox unless the account is at its limit. The
+// account row is locked while counting, so concurrent first messages can not
+// both take the last slot.
+func (q *Quota) CreateChatbox(ctx context.Context, account model.Account, limits Limits, data model.Chatbox) (model.Chatbox, error) {
+	var chatbox model.Chatbox
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if limits.MaxChatboxes > 0 {
+			if _, result := repository.NewAccountRepository(tx).OneByIdForUpdate(int(account.ID)); result.Error != nil {
+				return result.Error
+			}
+			count, result := repository.NewChatboxRepository(tx).CountByAccountID(int(account.ID))
+			if result.Error != nil {
+				return result.Error
+			}
+			if int(count) >= limits.MaxChatboxes {
+				return ErrChatboxQuotaExceeded
+			}
+		}
+		var result *gorm.DB
+		chatbox, result = repository.NewChatboxRepository(tx).Create(data)
+		return result.Error
+	})
+	return chatbox, err
+}
+
+func remaining(limit int, used int) int {
+	if limit <= 0 {
+		return -1
+	}
+	if used > limit {
+		return 0
+	}
+	return limit - used
+}
+
+func nextReset() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+func messageKey(account model.Account) string {
+	return fmt.Sprintf("quota:messages:%d:%s", account.ID, time.Now().Format("2006-01-02"))
+}
@@ -0,0 +1,193 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// PermissionAll grants every permission
+	PermissionAll = "*"
+
+	PermissionAccountsRead        = "accounts:read"
+	PermissionAccountsWrite       = "accounts:write"
+	PermissionAccountsImpersonate = "accounts:impersonate"
+	PermissionChatsRead           = "chats:read"
+	PermissionContentWrite        = "content:write"
+	PermissionVouchersRead        = "vouchers:read"
+	PermissionVouchersWrite       = "vouchers:write"
+	PermissionReportsRead         = "reports:read"
+	PermissionRolesWrite          = "roles:write"
+	PermissionAuditRead           = "audit:read"
+	PermissionApiKeysWrite        = "api_keys:write"
+)
+
+// Permissions lists every permission a role can be granted.
+var Permissions = []string{
+	PermissionAll,
+	PermissionAccountsRead,
+	PermissionAccountsWrite,
+	PermissionAccountsImpersonate,
+	PermissionChatsRead,
+	PermissionContentWrite,
+	PermissionVouchersRead,
+	PermissionVouchersWrite,
+	PermissionReportsRead,
+	PermissionRolesWrite,
+	PermissionAuditRead,
+	PermissionApiKeysWrite,
+}
+
+// DefaultRoles are created by the migrate command when missing.
+var DefaultRoles = []struct {
+	Code        string
+	Name        string
+	Permissions []string
+}{
+	{model.RoleAdmin, "Admin", []string{PermissionAll}},
+	{model.RoleSupport, "Support", []string{PermissionAccountsRead, PermissionAccountsImpersonate, PermissionChatsRead}},
+	{model.RoleContentEditor, "Content Editor", []string{PermissionContentWrite}},
+	{model.RoleFinance, "Finance", []string{PermissionAccountsRead, PermissionVouchersRead, PermissionVouchersWrite, PermissionReportsRead}},
+}
+
+func IsPermission(permission string) bool {
+	for _, v := range Permissions {
+		if v == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer resolves the permissions of the roles carried by an access
+// token. Role permissions are cached for a short TTL and dropped whenever
+// they are edited, so changes apply without logging in again.
+type Authorizer struct {
+	db    *gorm.DB
+	cache cache.Store
+	ttl   time.Duration
+}
+
+func NewAuthorizer(db *gorm.DB, store cache.Store, ttl time.Duration) *Authorizer {
+	return &Authorizer{
+		db:    db,
+		cache: store,
+		ttl:   ttl,
+	}
+}
+
+// Can tells whether any of the roles grants the permission.
+func (a *Authorizer) Can(ctx context.Context, roles []string, permission string) (bool, error) {
+	for _, role := range roles {
+		permissions, err := a.rolePermissions(ctx, role)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range permissions {
+			if v == PermissionAll || v == permission {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Invalidate drops the cached permissions of a role, call it whenever they
+// change.
+func (a *Authorizer) Invalidate(ctx context.Context, role string) {
+	if err := a.cache.Delete(ctx, cacheKey(role)); err != nil {
+		log.WithError(err).WithField("role", role).Error("error invalidate role cache")
+	}
+}
+
+func (a *Authorizer) rolePermissions(ctx context.Context, role string) ([]string, error) {
+	key := cacheKey(role)
+	if cached, err := a.cache.Get(ctx, key); err == nil {
+		if cached == "" {
+			return nil, nil
+		}
+		return strings.Split(cached, ","), nil
+	} else if !errors.Is(err, cache.ErrMiss) {
+		log.WithError(err).WithField("role", role).Warn("role cache unavailable")
+	}
+
+	found, result := repository.NewRoleRepository(a.db).OneByCode(role, "Permissions")
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	// unknown roles are cached too, they grant nothing
+	permissions := make([]string, 0, len(found.Permissions))
+	for _, v := range found.Permissions {
+		permissions = append(permissions, v.Permission)
+	}
+	if err := a.cache.Set(ctx, key, strings.Join(permissions, ","), a.ttl); err != nil {
+		log.WithError(err).WithField("role", role).Warn("role cache unavailable")
+	}
+	return permissions, nil
+}
+
+// RolesOf returns the role codes of an account, sorted.
+func RolesOf(db *gorm.DB, accountId uint) ([]string, error) {
+	accountRoles, result := repository.NewAccountRoleRepository(db).AllByAccountID(int(accountId), "Role")
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	roles := make([]string, 0, len(accountRoles))
+	for _, v := range accountRoles {
+		// the role was deleted
+		if v.Role.Code == "" {
+			continue
+		}
+		roles = append(roles, v.Role.Code)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// Seed creates the default roles that do not exist yet and gives the ADMIN
+// role to accounts of type ADMIN without any role, which is how admins were
+// told apart before roles existed. Existing roles are left as edited.
+func Seed(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		roleRepo := repository.NewRoleRepository(tx)
+		for _, v := range DefaultRoles {
+			_, result := roleRepo.OneByCode(v.Code)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				continue
+			}
+			role, result := roleRepo.Create(model.Role{Code: v.Code, Name: v.Name})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result := repository.NewRolePermissionRepository(tx).ReplaceByRoleID(int(role.ID), v.Permissions); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		admin, result := roleRepo.OneByCode(model.RoleAdmin)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Exec(`INSERT INTO account_roles (account_id, role_id)
+			SELECT a.id, ? FROM accounts a
+			WHERE a.type = ? AND a.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM account_roles r WHERE r.account_id = a.id)`,
+			admin.ID, model.AccountTypeAdmin).Error
+	})
+}
+
+func cacheKey(role string) string {
+	return "role_permissions:" + role
+}
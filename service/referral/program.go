@@ -0,0 +1,162 @@
+package referral
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCode = errors.New("invalid referral code")
+
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Program attaches referees to referrers on registration and rewards the
+// referrer once the referee's first payment settles.
+type Program struct {
+	trialDays  int
+	rewardDays int
+}
+
+func NewProgram(trialDays int, rewardDays int) *Program {
+	return &Program{
+		trialDays:  trialDays,
+		rewardDays: rewardDays,
+	}
+}
+
+// GenerateCode returns a random 8 character code without ambiguous letters.
+func GenerateCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := 0; i < 8; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// Attach records the referral of a freshly created referee and grants the
+// trial. Self-referrals and emails or phones that were already referred are
+// recorded as rejected so they show up in the admin report.
+func (p *Program) Attach(tx *gorm.DB, referee model.Account, code string) (model.Referral, error) {
+	accountRepo := repository.NewAccountRepository(tx)
+	referrer, result := accountRepo.OneByReferralCode(strings.ToUpper(strings.TrimSpace(code)))
+	if result.Error != nil {
+		return model.Referral{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.Referral{}, ErrInvalidCode
+	}
+
+	referral := model.Referral{
+		ReferrerID:   referrer.ID,
+		RefereeID:    referee.ID,
+		RefereeEmail: NormalizeEmail(referee.Email),
+		RefereePhone: NormalizePhone(referee.Phone()),
+		Status:       model.ReferralStatusPending,
+	}
+
+	referralRepo := repository.NewReferralRepository(tx)
+	count, result := referralRepo.CountByRefereeEmailOrPhone(referral.RefereeEmail, referral.RefereePhone)
+	if result.Error != nil {
+		return referral, result.Error
+	}
+	switch {
+	case referrer.ID == referee.ID ||
+		NormalizeEmail(referrer.Email) == referral.RefereeEmail ||
+		NormalizePhone(referrer.Phone()) == referral.RefereePhone:
+		referral.Status = model.ReferralStatusRejected
+		referral.RejectReason = "self referral"
+	case count > 0:
+		referral.Status = model.ReferralStatusRejected
+		referral.RejectReason = "email or phone already referred"
+	}
+
+	referral, result = referralRepo.Create(referral)
+	if result.Error != nil {
+		return referral, result.Error
+	}
+	if referral.Status == model.ReferralStatusRejected {
+		return referral, nil
+	}
+
+	if _, result := accountRepo.Update(int(referee.ID), model.Account{ReferredByID: &referrer.ID}); result.Error != nil {
+		return referral, result.Error
+	}
+	if p.trialDays > 0 {
+		if _, result := accountRepo.ExtendValidUntil(int(referee.ID), p.trialDays); result.Error != nil {
+			return referral, result.Error
+		}
+	}
+	return referral, nil
+}
+
+// Reward grants the referrer premium days the first time one of the
+// referee's orders settles. The referral is rewarded once, a refund and a
+// later payment do not pay out again. Call it in the same transaction that
+// marks the order paid.
+func (p *Program) Reward(tx *gorm.DB, refereeId int) (model.Account, bool, error) {
+	referralRepo := repository.NewReferralRepository(tx)
+	referral, result := referralRepo.OneByRefereeIDForUpdate(refereeId)
+	if result.Error != nil || result.RowsAffected == 0 || referral.Status != model.ReferralStatusPending {
+		return model.Account{}, false, result.Error
+	}
+
+	now := time.Now()
+	result = tx.Model(&model.Referral{}).
+		Where("id = ? AND status = ?", referral.ID, model.ReferralStatusPending).
+		Updates(map[string]interface{}{
+			"status":      model.ReferralStatusRewarded,
+			"reward_days": p.rewardDays,
+			"rewarded_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return model.Account{}, false, result.Error
+	}
+
+	referrer, result := repository.NewAccountRepository(tx).ExtendValidUntil(int(referral.ReferrerID), p.rewardDays)
+	if result.Error != nil {
+		return referrer, false, result.Error
+	}
+	return referrer, true, nil
+}
+
+// NormalizeEmail lowercases and drops "+tag" suffixes so aliases of the
+// same mailbox compare equal.
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return email
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// NormalizePhone keeps digits only and rewrites a local 0 prefix to 62.
+func NormalizePhone(phone string) string {
+	var sb strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	digits := sb.String()
+	if strings.HasPrefix(digits, "0") {
+		digits = "62" + digits[1:]
+	}
+	return digits
+}
@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"time"
 
 	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AccountRepository struct {
@@ -23,6 +26,35 @@ func NewAccountRepository(db *gorm.DB) *AccountRepository {
 
 func (s *AccountRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		if q.Get("deleted") == "true" {
+			db = db.Unscoped().Where("deleted_at IS NOT NULL")
+		}
+		if accountType := q.Get("type"); accountType != "" {
+			db = db.Where("type = ?", accountType)
+		}
+		today := datatypes.Date(time.Now())
+		switch q.Get("premium") {
+		case "true":
+			db = db.Where("valid_until >= ?", today)
+		case "false":
+			db = db.Where("valid_until IS NULL OR valid_until < ?", today)
+		}
+		switch q.Get("suspended") {
+		case "true":
+			db = db.Where("suspended_at IS NOT NULL")
+		case "false":
+			db = db.Where("suspended_at IS NULL")
+		}
+		if from, err := time.ParseInLocation("2006-01-02", q.Get("created_from"), time.Local); err == nil {
+			db = db.Where("created_at >= ?", from)
+		}
+		if to, err := time.ParseInLocation("2006-01-02", q.Get("created_to"), time.Local); err == nil {
+			db = db.Where("created_at < ?", to.AddDate(0, 0, 1))
+		}
+		if email := q.Get("email"); email != "" {
+			db = db.Where("email LIKE ?", "%"+email+"%")
+		}
 		return db
 	}
 }
@@ -43,17 +75,7 @@ func (s *AccountRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *go
 			pageSize = 10
 		}
 
-		sortBy := q.Get("sort_by")
-		if sortBy == "" {
-			sortBy = "id"
-		}
-
-		direction := q.Get("direction")
-		if direction == "" {
-			direction = "desc"
-		}
-
-		sort := sortBy + " " + direction
+		sort := sortOrder(q, "name", "email", "type", "valid_until", "plan_code", "suspended_at", "created_at", "updated_at", "deleted_at")
 
 		offset := (page - 1) * pageSize
 		return db.Offset(offset).Limit(pageSize).Order(sort)
@@ -149,6 +171,16 @@ func (s *AccountRepository) Update(id int, data model.Account) (model.Account, *
 	return table, query
 }
 
+// UpdateFields writes the given columns as they are, empty values included,
+// which Update skips.
+func (s *AccountRepository) UpdateFields(id int, fields map[string]interface{}) (model.Account, *gorm.DB) {
+	query := s.db.Model(&model.Account{}).Where("id = ?", id).Updates(fields)
+	if query.Error != nil {
+		return model.Account{}, query
+	}
+	return s.OneById(id)
+}
+
 func (s *AccountRepository) Delete(id int, isHard bool) *gorm.DB {
 	tx := s.db
 	if isHard {
@@ -181,3 +213,120 @@ func (s *AccountRepository) OneByEmail(email string, preload ...string) (model.A
 
 	return table, query
 }
+
+// OneByIdForUpdate locks the account row until the transaction ends, to
+// serialize changes that are checked against other tables.
+func (s *AccountRepository) OneByIdForUpdate(id int) (model.Account, *gorm.DB) {
+	var table model.Account
+	query := s.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&table)
+
+	return table, query
+}
+
+// Extend premium validity by the given days, counting from today when the
+// account has already lapsed. Negative days shorten the validity (refunds).
+// The row is locked so concurrent extensions add up.
+func (s *AccountRepository) ExtendValidUntil(id int, days int) (model.Account, *gorm.DB) {
+	var table model.Account
+	query := s.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&table)
+	if query.Error != nil {
+		return table, query
+	}
+	if query.RowsAffected == 0 {
+		query.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, query
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	base := time.Time(table.ValidUntil)
+	if days > 0 && base.Before(today) {
+		base = today
+	}
+	table.ValidUntil = datatypes.Date(base.AddDate(0, 0, days))
+	query = s.db.Model(&table).Update("valid_until", table.ValidUntil)
+	return table, query
+}
+
+func (s *AccountRepository) AllByValidUntil(validUntil time.Time, preload ...string) ([]model.Account, *gorm.DB) {
+	var table []model.Account
+	tx := s.db.Where("valid_until = ?", datatypes.Date(validUntil))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRepository) OneByReferralCode(code string, preload ...string) (model.Account, *gorm.DB) {
+	var table model.Account
+	tx := s.db.Where("referral_code = ?", code)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRepository) OneByPhoneNumber(phoneNumber string, preload ...string) (model.Account, *gorm.DB) {
+	var table model.Account
+	tx := s.db.Where("phone_number = ?", phoneNumber)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Only a verified phone number can be used to sign in
+func (s *AccountRepository) OneByVerifiedPhoneNumber(phoneNumber string, preload ...string) (model.Account, *gorm.DB) {
+	var table model.Account
+	tx := s.db.Where("phone_number = ? AND phone_verified_at IS NOT NULL", phoneNumber)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Get the accounts their owner deleted before the given time. Accounts an
+// admin deleted are kept until restored.
+func (s *AccountRepository) AllDeletionRequestedBefore(before time.Time) ([]model.Account, *gorm.DB) {
+	var table []model.Account
+	query := s.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deletion_requested_at IS NOT NULL AND deletion_requested_at < ?", before).
+		Find(&table)
+	return table, query
+}
+
+// Find an account including soft deleted ones
+func (s *AccountRepository) OneByIdWithDeleted(id int) (model.Account, *gorm.DB) {
+	var table model.Account
+	query := s.db.Unscoped().Where("id = ?", id).Find(&table)
+	return table, query
+}
+
+func (s *AccountRepository) Suspend(id int, reason string) *gorm.DB {
+	query := s.db.Model(&model.Account{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"suspended_at": time.Now(), "suspended_reason": reason})
+	return query
+}
+
+// Zero values are skipped by Update, clear the columns explicitly
+func (s *AccountRepository) Unsuspend(id int) *gorm.DB {
+	query := s.db.Model(&model.Account{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"suspended_at": nil, "suspended_reason": ""})
+	return query
+}
+
+// Undo a soft delete
+func (s *AccountRepository) Restore(id int) *gorm.DB {
+	query := s.db.Unscoped().Model(&model.Account{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "deleted_by": nil, "deletion_requested_at": nil})
+	return query
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type AccountRoleRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRoleRepository(db *gorm.DB) *AccountRoleRepository {
+	return &AccountRoleRepository{
+		db: db,
+	}
+}
+
+func (s *AccountRoleRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *AccountRoleRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *AccountRoleRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.AccountRole{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *AccountRoleRepository) Index(r *http.Request, preload ...string) ([]model.AccountRole, *gorm.DB) {
+	var table []model.AccountRole
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRoleRepository) All(r *http.Request, preload ...string) ([]model.AccountRole, *gorm.DB) {
+	var table []model.AccountRole
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRoleRepository) One(r *http.Request, preload ...string) (model.AccountRole, *gorm.DB) {
+	var table model.AccountRole
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRoleRepository) OneById(id int, preload ...string) (model.AccountRole, *gorm.DB) {
+	var table model.AccountRole
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRoleRepository) Create(data model.AccountRole) (model.AccountRole, *gorm.DB) {
+	var table model.AccountRole
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *AccountRoleRepository) Update(id int, data model.AccountRole) (model.AccountRole, *gorm.DB) {
+	var table model.AccountRole
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *AccountRoleRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.AccountRole{}, id)
+	return query
+}
+
+func (s *AccountRoleRepository) AssignData(table *model.AccountRole, data model.AccountRole) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *AccountRoleRepository) AllByAccountID(accountId int, preload ...string) ([]model.AccountRole, *gorm.DB) {
+	var table []model.AccountRole
+	tx := s.db.Where("account_id = ?", accountId)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AccountRoleRepository) ReplaceByAccountID(accountId int, roleIds []uint) *gorm.DB {
+	query := s.db.Unscoped().Where("account_id = ?", accountId).Delete(&model.AccountRole{})
+	if query.Error != nil || len(roleIds) == 0 {
+		return query
+	}
+	table := make([]model.AccountRole, 0, len(roleIds))
+	for _, v := range roleIds {
+		table = append(table, model.AccountRole{AccountID: uint(accountId), RoleID: v})
+	}
+	query = s.db.Create(&table)
+	return query
+}
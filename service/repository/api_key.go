@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type ApiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewApiKeyRepository(db *gorm.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		db: db,
+	}
+}
+
+func (s *ApiKeyRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if partner := r.URL.Query().Get("partner"); partner != "" {
+			db = db.Where("partner LIKE ?", "%"+partner+"%")
+		}
+		return db
+	}
+}
+
+func (s *ApiKeyRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sort := sortOrder(q, "partner", "prefix", "last_used_at", "expires_at", "created_at", "updated_at")
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *ApiKeyRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.ApiKey{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *ApiKeyRepository) Index(r *http.Request, preload ...string) ([]model.ApiKey, *gorm.DB) {
+	var table []model.ApiKey
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ApiKeyRepository) All(r *http.Request, preload ...string) ([]model.ApiKey, *gorm.DB) {
+	var table []model.ApiKey
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ApiKeyRepository) One(r *http.Request, preload ...string) (model.ApiKey, *gorm.DB) {
+	var table model.ApiKey
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ApiKeyRepository) OneById(id int, preload ...string) (model.ApiKey, *gorm.DB) {
+	var table model.ApiKey
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ApiKeyRepository) OneByPrefix(prefix string, preload ...string) (model.ApiKey, *gorm.DB) {
+	var table model.ApiKey
+	tx := s.db.Where("prefix = ?", prefix)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ApiKeyRepository) Create(data model.ApiKey) (model.ApiKey, *gorm.DB) {
+	var table model.ApiKey
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *ApiKeyRepository) Update(id int, data model.ApiKey) (model.ApiKey, *gorm.DB) {
+	var table model.ApiKey
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *ApiKeyRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.ApiKey{}, id)
+	return query
+}
+
+// UpdateFields writes fields as given, zero values included.
+func (s *ApiKeyRepository) UpdateFields(id int, fields map[string]interface{}) (model.ApiKey, *gorm.DB) {
+	query := s.db.Model(&model.ApiKey{}).Where("id = ?", id).Updates(fields)
+	if query.Error != nil {
+		return model.ApiKey{}, query
+	}
+	return s.OneById(id)
+}
+
+// TouchLastUsed records a use of the key without bumping its audit columns.
+func (s *ApiKeyRepository) TouchLastUsed(id int, at time.Time) *gorm.DB {
+	return s.db.Model(&model.ApiKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at)
+}
+
+func (s *ApiKeyRepository) AssignData(table *model.ApiKey, data model.ApiKey) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+// Audit events are append-only, the repository has no Update or Delete
+type AuditEventRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditEventRepository(db *gorm.DB) *AuditEventRepository {
+	return &AuditEventRepository{
+		db: db,
+	}
+}
+
+func (s *AuditEventRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		for _, column := range []string{"actor", "action", "target_type", "ip_address", "request_id"} {
+			if value := q.Get(column); value != "" {
+				db = db.Where(column+" = ?", value)
+			}
+		}
+		if targetId, err := strconv.Atoi(q.Get("target_id")); err == nil {
+			db = db.Where("target_id = ?", targetId)
+		}
+		if from, err := time.ParseInLocation("2006-01-02", q.Get("created_from"), time.Local); err == nil {
+			db = db.Where("created_at >= ?", from)
+		}
+		if to, err := time.ParseInLocation("2006-01-02", q.Get("created_to"), time.Local); err == nil {
+			db = db.Where("created_at < ?", to.AddDate(0, 0, 1))
+		}
+		return db
+	}
+}
+
+func (s *AuditEventRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sort := sortOrder(q, "actor", "action", "target_type", "target_id", "created_at")
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *AuditEventRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.AuditEvent{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *AuditEventRepository) Index(r *http.Request, preload ...string) ([]model.AuditEvent, *gorm.DB) {
+	var table []model.AuditEvent
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AuditEventRepository) All(r *http.Request, preload ...string) ([]model.AuditEvent, *gorm.DB) {
+	var table []model.AuditEvent
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AuditEventRepository) One(r *http.Request, preload ...string) (model.AuditEvent, *gorm.DB) {
+	var table model.AuditEvent
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *AuditEventRepository) OneById(id int, preload ...string) (model.AuditEvent, *gorm.DB) {
+	var table model.AuditEvent
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// EachFiltered walks the filtered events in id order, in batches so large
+// exports do not load the whole table.
+func (s *AuditEventRepository) EachFiltered(r *http.Request, batchSize int, fn func([]model.AuditEvent) error) *gorm.DB {
+	var batch []model.AuditEvent
+	return s.db.Scopes(s.FilterScope(r)).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	})
+}
+
+func (s *AuditEventRepository) Create(data model.AuditEvent) (model.AuditEvent, *gorm.DB) {
+	var table model.AuditEvent
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *AuditEventRepository) AssignData(table *model.AuditEvent, data model.AuditEvent) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
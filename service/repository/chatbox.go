@@ -214,3 +214,10 @@ func (s *ChatboxRepository) AllByAccountID(accountId int, preload ...string) ([]
 
 	return table, query
 }
+
+func (s *ChatboxRepository) CountByAccountID(accountId int) (int64, *gorm.DB) {
+	var count int64
+	query := s.db.Model(&model.Chatbox{}).Where("account_id = ?", accountId).Count(&count)
+
+	return count, query
+}
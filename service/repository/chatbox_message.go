@@ -181,3 +181,9 @@ func (s *ChatboxMessageRepository) AllByChatboxCode(chatboxCode string, preload
 
 	return table, query
 }
+
+// Hard delete the messages of chatboxes
+func (s *ChatboxMessageRepository) PurgeByChatboxCodes(chatboxCodes []string) *gorm.DB {
+	query := s.db.Unscoped().Where("chatbox_code IN ?", chatboxCodes).Delete(&model.ChatboxMessage{})
+	return query
+}
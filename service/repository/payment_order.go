@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type PaymentOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentOrderRepository(db *gorm.DB) *PaymentOrderRepository {
+	return &PaymentOrderRepository{
+		db: db,
+	}
+}
+
+func (s *PaymentOrderRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *PaymentOrderRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *PaymentOrderRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.PaymentOrder{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *PaymentOrderRepository) Index(r *http.Request, preload ...string) ([]model.PaymentOrder, *gorm.DB) {
+	var table []model.PaymentOrder
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *PaymentOrderRepository) All(r *http.Request, preload ...string) ([]model.PaymentOrder, *gorm.DB) {
+	var table []model.PaymentOrder
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *PaymentOrderRepository) One(r *http.Request, preload ...string) (model.PaymentOrder, *gorm.DB) {
+	var table model.PaymentOrder
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *PaymentOrderRepository) OneById(id int, preload ...string) (model.PaymentOrder, *gorm.DB) {
+	var table model.PaymentOrder
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *PaymentOrderRepository) Create(data model.PaymentOrder) (model.PaymentOrder, *gorm.DB) {
+	var table model.PaymentOrder
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *PaymentOrderRepository) Update(id int, data model.PaymentOrder) (model.PaymentOrder, *gorm.DB) {
+	var table model.PaymentOrder
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *PaymentOrderRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.PaymentOrder{}, id)
+	return query
+}
+
+func (s *PaymentOrderRepository) AssignData(table *model.PaymentOrder, data model.PaymentOrder) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *PaymentOrderRepository) OneByCode(code string, preload ...string) (model.PaymentOrder, *gorm.DB) {
+	var table model.PaymentOrder
+	tx := s.db.Where("code = ?", code)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *PaymentOrderRepository) OneByCodeAndAccountID(code string, accountId int, preload ...string) (model.PaymentOrder, *gorm.DB) {
+	var table model.PaymentOrder
+	tx := s.db.Where("code = ? AND account_id = ?", code, accountId)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *PaymentOrderRepository) AllByAccountID(accountId int, preload ...string) ([]model.PaymentOrder, *gorm.DB) {
+	var table []model.PaymentOrder
+	tx := s.db.Where("account_id = ?", accountId).Order("id DESC").Limit(100)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Move an order to status "to" only if it is currently in one of "from".
+// RowsAffected is 0 when the transition was already applied or is not allowed,
+// which keeps repeated webhook deliveries idempotent.
+func (s *PaymentOrderRepository) Transition(code string, to string, from ...string) *gorm.DB {
+	values := map[string]interface{}{"status": to}
+	if to == model.PaymentOrderStatusPaid {
+		values["paid_at"] = time.Now()
+	}
+	query := s.db.Model(&model.PaymentOrder{}).
+		Where("code = ? AND status IN ?", code, from).
+		Updates(values)
+	return query
+}
+
+// Get every order of an account, oldest first
+func (s *PaymentOrderRepository) HistoryByAccountID(accountId int, preload ...string) ([]model.PaymentOrder, *gorm.DB) {
+	var table []model.PaymentOrder
+	tx := s.db.Where("account_id = ?", accountId).Order("id ASC")
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
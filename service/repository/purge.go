@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// PurgeByAccountID hard deletes the rows of every given table that belong
+// to an account through an account_id column.
+func PurgeByAccountID(db *gorm.DB, accountId int, tables ...interface{}) error {
+	for _, table := range tables {
+		if err := db.Unscoped().Where("account_id = ?", accountId).Delete(table).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReferralRepository struct {
+	db *gorm.DB
+}
+
+func NewReferralRepository(db *gorm.DB) *ReferralRepository {
+	return &ReferralRepository{
+		db: db,
+	}
+}
+
+func (s *ReferralRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		if status := q.Get("status"); status != "" {
+			db = db.Where("status = ?", status)
+		}
+		if referrerId := q.Get("referrer_id"); referrerId != "" {
+			db = db.Where("referrer_id = ?", referrerId)
+		}
+		return db
+	}
+}
+
+func (s *ReferralRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sort := sortOrder(q, "referrer_id", "referee_id", "status", "rewarded_at", "created_at", "updated_at")
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *ReferralRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.Referral{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *ReferralRepository) Index(r *http.Request, preload ...string) ([]model.Referral, *gorm.DB) {
+	var table []model.Referral
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ReferralRepository) All(r *http.Request, preload ...string) ([]model.Referral, *gorm.DB) {
+	var table []model.Referral
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ReferralRepository) One(r *http.Request, preload ...string) (model.Referral, *gorm.DB) {
+	var table model.Referral
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ReferralRepository) OneById(id int, preload ...string) (model.Referral, *gorm.DB) {
+	var table model.Referral
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *ReferralRepository) Create(data model.Referral) (model.Referral, *gorm.DB) {
+	var table model.Referral
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *ReferralRepository) Update(id int, data model.Referral) (model.Referral, *gorm.DB) {
+	var table model.Referral
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *ReferralRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.Referral{}, id)
+	return query
+}
+
+func (s *ReferralRepository) AssignData(table *model.Referral, data model.Referral) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *ReferralRepository) OneByRefereeID(refereeId int, preload ...string) (model.Referral, *gorm.DB) {
+	var table model.Referral
+	tx := s.db.Where("referee_id = ?", refereeId)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Lock the referral of the referee for the rest of the transaction so two
+// settling orders cannot both reward it.
+func (s *ReferralRepository) OneByRefereeIDForUpdate(refereeId int) (model.Referral, *gorm.DB) {
+	var table model.Referral
+	query := s.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("referee_id = ?", refereeId).Find(&table)
+
+	return table, query
+}
+
+func (s *ReferralRepository) AllByReferrerID(referrerId int, preload ...string) ([]model.Referral, *gorm.DB) {
+	var table []model.Referral
+	tx := s.db.Where("referrer_id = ?", referrerId).Order("id DESC").Limit(100)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Count referrals, including deleted ones, already made for the email or phone
+func (s *ReferralRepository) CountByRefereeEmailOrPhone(email string, phone string) (int64, *gorm.DB) {
+	var count int64
+	query := s.db.Unscoped().Model(&model.Referral{}).
+		Where("referee_email = ? OR referee_phone = ?", email, phone).
+		Count(&count)
+
+	return count, query
+}
+
+type ReferralSummary struct {
+	ReferrerID uint   `json:"referrer_id"`
+	Status     string `json:"status"`
+	Total      int64  `json:"total"`
+	RewardDays int64  `json:"reward_days"`
+}
+
+// Summarize referrals by referrer and status, referrerId 0 covers every referrer
+func (s *ReferralRepository) Summary(r *http.Request, referrerId int) ([]ReferralSummary, *gorm.DB) {
+	var table []ReferralSummary
+	tx := s.db.Model(&model.Referral{}).Scopes(s.FilterScope(r))
+	if referrerId != 0 {
+		tx = tx.Where("referrer_id = ?", referrerId)
+	}
+	query := tx.Select("referrer_id, status, COUNT(*) AS total, SUM(reward_days) AS reward_days").
+		Group("referrer_id, status").
+		Order("total DESC").
+		Limit(500).
+		Scan(&table)
+
+	return table, query
+}
+
+// Hard delete the referral an account was invited with and the ones it
+// made as the referrer
+func (s *ReferralRepository) PurgeByAccountID(accountId int) *gorm.DB {
+	query := s.db.Unscoped().Where("referee_id = ? OR referrer_id = ?", accountId, accountId).Delete(&model.Referral{})
+	return query
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: db,
+	}
+}
+
+func (s *RefreshTokenRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *RefreshTokenRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *RefreshTokenRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.RefreshToken{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *RefreshTokenRepository) Index(r *http.Request, preload ...string) ([]model.RefreshToken, *gorm.DB) {
+	var table []model.RefreshToken
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *RefreshTokenRepository) All(r *http.Request, preload ...string) ([]model.RefreshToken, *gorm.DB) {
+	var table []model.RefreshToken
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *RefreshTokenRepository) One(r *http.Request, preload ...string) (model.RefreshToken, *gorm.DB) {
+	var table model.RefreshToken
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *RefreshTokenRepository) OneById(id int, preload ...string) (model.RefreshToken, *gorm.DB) {
+	var table model.RefreshToken
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *RefreshTokenRepository) Create(data model.RefreshToken) (model.RefreshToken, *gorm.DB) {
+	var table model.RefreshToken
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *RefreshTokenRepository) Update(id int, data model.RefreshToken) (model.RefreshToken, *gorm.DB) {
+	var table model.RefreshToken
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *RefreshTokenRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.RefreshToken{}, id)
+	return query
+}
+
+func (s *RefreshTokenRepository) AssignData(table *model.RefreshToken, data model.RefreshToken) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *RefreshTokenRepository) OneByHash(tokenHash string, preload ...string) (model.RefreshToken, *gorm.DB) {
+	var table model.RefreshToken
+	tx := s.db.Where("token_hash = ?", tokenHash)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Mark the token rotated only when it is still active, a RowsAffected of 0
+// means it was already used or revoked.
+func (s *RefreshTokenRepository) MarkRotated(id int) *gorm.DB {
+	now := time.Now()
+	query := s.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, now).
+		Update("rotated_at", now)
+	return query
+}
+
+// Revoke every token of a rotation family
+func (s *RefreshTokenRepository) RevokeFamily(familyId string) *gorm.DB {
+	query := s.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now())
+	return query
+}
+
+// Revoke the tokens of an account except the family kept, if any
+func (s *RefreshTokenRepository) RevokeByAccountID(accountId int, keepFamilyId string) *gorm.DB {
+	query := s.db.Model(&model.RefreshToken{}).
+		Where("account_id = ? AND family_id <> ? AND revoked_at IS NULL", accountId, keepFamilyId).
+		Update("revoked_at", time.Now())
+	return query
+}
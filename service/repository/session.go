@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+func (s *SessionRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *SessionRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *SessionRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.Session{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *SessionRepository) Index(r *http.Request, preload ...string) ([]model.Session, *gorm.DB) {
+	var table []model.Session
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *SessionRepository) All(r *http.Request, preload ...string) ([]model.Session, *gorm.DB) {
+	var table []model.Session
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *SessionRepository) One(r *http.Request, preload ...string) (model.Session, *gorm.DB) {
+	var table model.Session
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *SessionRepository) OneById(id int, preload ...string) (model.Session, *gorm.DB) {
+	var table model.Session
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *SessionRepository) Create(data model.Session) (model.Session, *gorm.DB) {
+	var table model.Session
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *SessionRepository) Update(id int, data model.Session) (model.Session, *gorm.DB) {
+	var table model.Session
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *SessionRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.Session{}, id)
+	return query
+}
+
+func (s *SessionRepository) AssignData(table *model.Session, data model.Session) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *SessionRepository) OneByFamilyID(familyId string, preload ...string) (model.Session, *gorm.DB) {
+	var table model.Session
+	tx := s.db.Where("family_id = ?", familyId)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Get the sessions of an account that are neither revoked nor expired
+func (s *SessionRepository) AllActiveByAccountID(accountId int, preload ...string) ([]model.Session, *gorm.DB) {
+	var table []model.Session
+	tx := s.db.Where("account_id = ? AND revoked_at IS NULL AND expires_at > ?", accountId, time.Now()).
+		Order("last_seen_at desc")
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Record device activity and push back the expiry after a refresh
+func (s *SessionRepository) Touch(familyId string, data model.Session) *gorm.DB {
+	values := map[string]interface{}{"last_seen_at": data.LastSeenAt}
+	if data.UserAgent != "" {
+		values["user_agent"] = data.UserAgent
+	}
+	if data.IpAddress != "" {
+		values["ip_address"] = data.IpAddress
+	}
+	if !data.ExpiresAt.IsZero() {
+		values["expires_at"] = data.ExpiresAt
+	}
+	query := s.db.Model(&model.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Updates(values)
+	return query
+}
+
+func (s *SessionRepository) Revoke(familyId string) *gorm.DB {
+	query := s.db.Model(&model.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now())
+	return query
+}
+
+// Revoke the sessions of an account except the one kept, if any
+func (s *SessionRepository) RevokeByAccountID(accountId int, keepFamilyId string) *gorm.DB {
+	query := s.db.Model(&model.Session{}).
+		Where("account_id = ? AND family_id <> ? AND revoked_at IS NULL", accountId, keepFamilyId).
+		Update("revoked_at", time.Now())
+	return query
+}
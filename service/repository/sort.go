@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"net/url"
+	"strings"
+)
+
+// sortOrder builds the ORDER BY of a listing from the sort_by and direction
+// query parameters. Both end up in raw SQL, so sort_by must be one of the
+// given columns, or id, and direction asc or desc; anything else sorts by
+// id desc.
+func sortOrder(q url.Values, columns ...string) string {
+	sortBy := q.Get("sort_by")
+	if sortBy == "" {
+		sortBy = "id"
+	}
+	allowed := sortBy == "id"
+	for _, v := range columns {
+		if v == sortBy {
+			allowed = true
+			break
+		}
+	}
+	direction := strings.ToLower(q.Get("direction"))
+	if direction == "" {
+		direction = "desc"
+	}
+	if !allowed || (direction != "asc" && direction != "desc") {
+		return "id desc"
+	}
+	return sortBy + " " + direction
+}
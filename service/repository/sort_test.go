@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSortOrder(t *testing.T) {
+	cases := map[string]string{
+		"":                                  "id desc",
+		"sort_by=name":                      "name desc",
+		"sort_by=name&direction=ASC":        "name asc",
+		"direction=asc":                     "id asc",
+		"sort_by=password":                  "id desc",
+		"sort_by=name&direction=sideways":   "id desc",
+		"sort_by=(select+totp_secret)":      "id desc",
+		"sort_by=name&direction=desc,+id+1": "id desc",
+	}
+	for query, want := range cases {
+		q, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatalf("parse %q: %v", query, err)
+		}
+		if got := sortOrder(q, "name", "email"); got != want {
+			t.Errorf("%q: sort = %q, want %q", query, got, want)
+		}
+	}
+}
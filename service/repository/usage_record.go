@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type UsageRecordRepository struct {
+	db *gorm.DB
+}
+
+func NewUsageRecordRepository(db *gorm.DB) *UsageRecordRepository {
+	return &UsageRecordRepository{
+		db: db,
+	}
+}
+
+func (s *UsageRecordRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *UsageRecordRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *UsageRecordRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.UsageRecord{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *UsageRecordRepository) Index(r *http.Request, preload ...string) ([]model.UsageRecord, *gorm.DB) {
+	var table []model.UsageRecord
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *UsageRecordRepository) All(r *http.Request, preload ...string) ([]model.UsageRecord, *gorm.DB) {
+	var table []model.UsageRecord
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *UsageRecordRepository) One(r *http.Request, preload ...string) (model.UsageRecord, *gorm.DB) {
+	var table model.UsageRecord
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *UsageRecordRepository) OneById(id int, preload ...string) (model.UsageRecord, *gorm.DB) {
+	var table model.UsageRecord
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *UsageRecordRepository) Create(data model.UsageRecord) (model.UsageRecord, *gorm.DB) {
+	var table model.UsageRecord
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *UsageRecordRepository) Update(id int, data model.UsageRecord) (model.UsageRecord, *gorm.DB) {
+	var table model.UsageRecord
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *UsageRecordRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.UsageRecord{}, id)
+	return query
+}
+
+func (s *UsageRecordRepository) AssignData(table *model.UsageRecord, data model.UsageRecord) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+type UsageAggregate struct {
+	Period           string  `json:"period"`
+	GroupKey         string  `json:"group_key"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+var usagePeriodFormats = map[string]string{
+	"daily":   "%Y-%m-%d",
+	"monthly": "%Y-%m",
+}
+
+var usageGroupColumns = map[string]string{
+	"account": "account_id",
+	"model":   "model",
+	"persona": "persona",
+}
+
+// Aggregate usage between from and to, bucketed by period ("daily" or
+// "monthly") and grouped by "account", "model", "persona" or nothing.
+// accountId limits the result to one account when not zero.
+func (s *UsageRecordRepository) Aggregate(period string, groupBy string, from time.Time, to time.Time, accountId int) ([]UsageAggregate, *gorm.DB) {
+	var table []UsageAggregate
+
+	format, ok := usagePeriodFormats[period]
+	if !ok {
+		format = usagePeriodFormats["daily"]
+	}
+	groupKey := "''"
+	if column, ok := usageGroupColumns[groupBy]; ok {
+		groupKey = column
+	}
+
+	tx := s.db.Model(&model.UsageRecord{}).
+		Select("DATE_FORMAT(created_at, ?) AS period, CAST("+groupKey+" AS CHAR) AS group_key, "+
+			"COUNT(*) AS requests, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, "+
+			"SUM(total_tokens) AS total_tokens, SUM(cost) AS cost", format).
+		Where("created_at >= ? AND created_at < ?", from, to)
+	if accountId != 0 {
+		tx = tx.Where("account_id = ?", accountId)
+	}
+	query := tx.Group("period, group_key").Order("period ASC, group_key ASC").Scan(&table)
+
+	return table, query
+}
+
+func (s *UsageRecordRepository) AllByAccountID(accountId int, preload ...string) ([]model.UsageRecord, *gorm.DB) {
+	var table []model.UsageRecord
+	tx := s.db.Where("account_id = ?", accountId).Order("id ASC")
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
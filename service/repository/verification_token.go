@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type VerificationTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationTokenRepository(db *gorm.DB) *VerificationTokenRepository {
+	return &VerificationTokenRepository{
+		db: db,
+	}
+}
+
+func (s *VerificationTokenRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *VerificationTokenRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *VerificationTokenRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.VerificationToken{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *VerificationTokenRepository) Index(r *http.Request, preload ...string) ([]model.VerificationToken, *gorm.DB) {
+	var table []model.VerificationToken
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VerificationTokenRepository) All(r *http.Request, preload ...string) ([]model.VerificationToken, *gorm.DB) {
+	var table []model.VerificationToken
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VerificationTokenRepository) One(r *http.Request, preload ...string) (model.VerificationToken, *gorm.DB) {
+	var table model.VerificationToken
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VerificationTokenRepository) OneById(id int, preload ...string) (model.VerificationToken, *gorm.DB) {
+	var table model.VerificationToken
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VerificationTokenRepository) Create(data model.VerificationToken) (model.VerificationToken, *gorm.DB) {
+	var table model.VerificationToken
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *VerificationTokenRepository) Update(id int, data model.VerificationToken) (model.VerificationToken, *gorm.DB) {
+	var table model.VerificationToken
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *VerificationTokenRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.VerificationToken{}, id)
+	return query
+}
+
+func (s *VerificationTokenRepository) AssignData(table *model.VerificationToken, data model.VerificationToken) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *VerificationTokenRepository) OneByPurposeAndHash(purpose string, tokenHash string, preload ...string) (model.VerificationToken, *gorm.DB) {
+	var table model.VerificationToken
+	tx := s.db.Where("purpose = ? AND token_hash = ?", purpose, tokenHash)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+// Mark the token used only when it is still unused and not expired, a
+// RowsAffected of 0 means somebody else consumed it first.
+func (s *VerificationTokenRepository) MarkUsed(id int) *gorm.DB {
+	now := time.Now()
+	query := s.db.Model(&model.VerificationToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	return query
+}
+
+// Invalidate the outstanding tokens of an account for a purpose
+func (s *VerificationTokenRepository) RevokeByAccountIDAndPurpose(accountId int, purpose string) *gorm.DB {
+	query := s.db.Model(&model.VerificationToken{}).
+		Where("account_id = ? AND purpose = ? AND used_at IS NULL", accountId, purpose).
+		Update("used_at", time.Now())
+	return query
+}
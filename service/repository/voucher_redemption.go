@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"gorm.io/gorm"
+)
+
+type VoucherRedemptionRepository struct {
+	db *gorm.DB
+}
+
+func NewVoucherRedemptionRepository(db *gorm.DB) *VoucherRedemptionRepository {
+	return &VoucherRedemptionRepository{
+		db: db,
+	}
+}
+
+func (s *VoucherRedemptionRepository) FilterScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db
+	}
+}
+
+func (s *VoucherRedemptionRepository) PaginateScope(r *http.Request) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageSize, _ := strconv.Atoi(q.Get("page_size"))
+		switch {
+		case pageSize > 100:
+			pageSize = 100
+		case pageSize <= 0:
+			pageSize = 10
+		}
+
+		sortBy := q.Get("sort_by")
+		if sortBy == "" {
+			sortBy = "id"
+		}
+
+		direction := q.Get("direction")
+		if direction == "" {
+			direction = "desc"
+		}
+
+		sort := sortBy + " " + direction
+
+		offset := (page - 1) * pageSize
+		return db.Offset(offset).Limit(pageSize).Order(sort)
+	}
+}
+
+func (s *VoucherRedemptionRepository) MetaPaginate(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+	var totalRows int64
+	s.db.Model(model.VoucherRedemption{}).Scopes(s.FilterScope(r)).Count(&totalRows)
+
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+	totalPages := int(math.Ceil(float64(totalRows) / float64(pageSize)))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	meta := map[string]interface{}{
+		"page":        page,
+		"page_size":   pageSize,
+		"total_rows":  totalRows,
+		"total_pages": totalPages,
+	}
+	return meta
+}
+
+func (s *VoucherRedemptionRepository) Index(r *http.Request, preload ...string) ([]model.VoucherRedemption, *gorm.DB) {
+	var table []model.VoucherRedemption
+	tx := s.db.Scopes(s.FilterScope(r), s.PaginateScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VoucherRedemptionRepository) All(r *http.Request, preload ...string) ([]model.VoucherRedemption, *gorm.DB) {
+	var table []model.VoucherRedemption
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VoucherRedemptionRepository) One(r *http.Request, preload ...string) (model.VoucherRedemption, *gorm.DB) {
+	var table model.VoucherRedemption
+	tx := s.db.Scopes(s.FilterScope(r))
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VoucherRedemptionRepository) OneById(id int, preload ...string) (model.VoucherRedemption, *gorm.DB) {
+	var table model.VoucherRedemption
+	tx := s.db.Where("id = ?", id)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
+
+func (s *VoucherRedemptionRepository) Create(data model.VoucherRedemption) (model.VoucherRedemption, *gorm.DB) {
+	var table model.VoucherRedemption
+	s.AssignData(&table, data)
+	query := s.db.Create(&table)
+	return table, query
+}
+
+func (s *VoucherRedemptionRepository) Update(id int, data model.VoucherRedemption) (model.VoucherRedemption, *gorm.DB) {
+	var table model.VoucherRedemption
+	table, result := s.OneById(id)
+	if result.RowsAffected == 0 {
+		result.Error = fmt.Errorf("data not found with id = %d", id)
+		return table, result
+	}
+	s.AssignData(&table, data)
+	query := s.db.Save(&table)
+	return table, query
+}
+
+func (s *VoucherRedemptionRepository) Delete(id int, isHard bool) *gorm.DB {
+	tx := s.db
+	if isHard {
+		tx = tx.Unscoped()
+	}
+	query := tx.Delete(&model.VoucherRedemption{}, id)
+	return query
+}
+
+func (s *VoucherRedemptionRepository) AssignData(table *model.VoucherRedemption, data model.VoucherRedemption) {
+	dataRV := reflect.ValueOf(data)
+	tableRV := reflect.ValueOf(table)
+	tableRVE := tableRV.Elem()
+
+	for i := 0; i < dataRV.NumField(); i++ {
+		if !dataRV.Field(i).IsZero() && (tableRVE.Field(i) != dataRV.Field(i)) {
+			fv := tableRVE.FieldByName(dataRV.Type().Field(i).Name)
+			fv.Set(dataRV.Field(i))
+		}
+	}
+}
+
+func (s *VoucherRedemptionRepository) CountByVoucherIDAndAccountID(voucherId int, accountId int) (int64, *gorm.DB) {
+	var count int64
+	query := s.db.Model(&model.VoucherRedemption{}).Where("voucher_id = ? AND account_id = ?", voucherId, accountId).Count(&count)
+
+	return count, query
+}
+
+func (s *VoucherRedemptionRepository) OneByOrderCode(orderCode string, preload ...string) (model.VoucherRedemption, *gorm.DB) {
+	var table model.VoucherRedemption
+	tx := s.db.Where("order_code = ?", orderCode)
+	for _, v := range preload {
+		tx = tx.Preload(v)
+	}
+	query := tx.Find(&table)
+
+	return table, query
+}
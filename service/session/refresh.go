@@ -0,0 +1,204 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, session revoked")
+)
+
+// Device is the client a refresh token was handed to.
+type Device struct {
+	UserAgent string
+	IpAddress string
+}
+
+// RefreshTokens issues opaque refresh tokens that are rotated on every use.
+// Only their SHA-256 hash is stored. Presenting a token that was already
+// rotated revokes its whole family, since either the client or an attacker
+// holds a stolen copy, together with the access tokens of the family.
+type RefreshTokens struct {
+	db      *gorm.DB
+	ttl     time.Duration
+	revoker *Revoker
+}
+
+func NewRefreshTokens(db *gorm.DB, ttl time.Duration, revoker *Revoker) *RefreshTokens {
+	return &RefreshTokens{
+		db:      db,
+		ttl:     ttl,
+		revoker: revoker,
+	}
+}
+
+// Issue starts a new session for a fresh login and returns the plain token
+// with the family id. twoFactor records that the login passed a second
+// factor, which refreshed tokens keep.
+func (s *RefreshTokens) Issue(accountId uint, device Device, twoFactor bool) (string, string, error) {
+	familyId, err := randomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	var plain string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		_, result := repository.NewSessionRepository(tx).Create(model.Session{
+			AccountID:  accountId,
+			FamilyID:   familyId,
+			UserAgent:  truncate(device.UserAgent, 512),
+			IpAddress:  truncate(device.IpAddress, 64),
+			TwoFactor:  twoFactor,
+			LastSeenAt: &now,
+			ExpiresAt:  now.Add(s.ttl),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		plain, err = s.create(tx, accountId, familyId, device)
+		return err
+	})
+	return plain, familyId, err
+}
+
+// Rotate exchanges a refresh token for a new one of the same family.
+func (s *RefreshTokens) Rotate(ctx context.Context, plain string, device Device) (string, model.RefreshToken, error) {
+	var next string
+	var token model.RefreshToken
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tokenRepo := repository.NewRefreshTokenRepository(tx)
+		var result *gorm.DB
+		token, result = tokenRepo.OneByHash(hash(plain))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || token.RevokedAt != nil || token.ExpiresAt.Before(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+		if token.RotatedAt != nil {
+			return ErrRefreshTokenReused
+		}
+
+		result = tokenRepo.MarkRotated(int(token.ID))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// lost a race against another use of the same token
+			return ErrRefreshTokenReused
+		}
+
+		now := time.Now()
+		if result := repository.NewSessionRepository(tx).Touch(token.FamilyID, model.Session{
+			UserAgent:  truncate(device.UserAgent, 512),
+			IpAddress:  truncate(device.IpAddress, 64),
+			LastSeenAt: &now,
+			ExpiresAt:  now.Add(s.ttl),
+		}); result.Error != nil {
+			return result.Error
+		}
+
+		var err error
+		next, err = s.create(tx, token.AccountID, token.FamilyID, device)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if revokeErr := s.RevokeFamily(token.FamilyID); revokeErr != nil {
+			return "", token, revokeErr
+		}
+		// the access token issued with the stolen copy dies too
+		if revokeErr := s.revoker.RevokeSession(ctx, token.FamilyID); revokeErr != nil {
+			return "", token, revokeErr
+		}
+	}
+	return next, token, err
+}
+
+// Sessions lists the active sessions of an account.
+func (s *RefreshTokens) Sessions(accountId uint) ([]model.Session, error) {
+	sessions, result := repository.NewSessionRepository(s.db).AllActiveByAccountID(int(accountId))
+	return sessions, result.Error
+}
+
+// RevokeFamily ends one session.
+func (s *RefreshTokens) RevokeFamily(familyId string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if result := repository.NewRefreshTokenRepository(tx).RevokeFamily(familyId); result.Error != nil {
+			return result.Error
+		}
+		return repository.NewSessionRepository(tx).Revoke(familyId).Error
+	})
+}
+
+// RevokeAccount ends every session of an account but keepFamilyId, which
+// may be empty, and returns the family ids of the sessions it ended.
+func (s *RefreshTokens) RevokeAccount(accountId uint, keepFamilyId string) ([]string, error) {
+	var familyIds []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		sessionRepo := repository.NewSessionRepository(tx)
+		sessions, result := sessionRepo.AllActiveByAccountID(int(accountId))
+		if result.Error != nil {
+			return result.Error
+		}
+		for _, v := range sessions {
+			if v.FamilyID != keepFamilyId {
+				familyIds = append(familyIds, v.FamilyID)
+			}
+		}
+		if result := repository.NewRefreshTokenRepository(tx).RevokeByAccountID(int(accountId), keepFamilyId); result.Error != nil {
+			return result.Error
+		}
+		return sessionRepo.RevokeByAccountID(int(accountId), keepFamilyId).Error
+	})
+	return familyIds, err
+}
+
+func (s *RefreshTokens) create(db *gorm.DB, accountId uint, familyId string, device Device) (string, error) {
+	plain, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	_, result := repository.NewRefreshTokenRepository(db).Create(model.RefreshToken{
+		AccountID: accountId,
+		FamilyID:  familyId,
+		TokenHash: hash(plain),
+		UserAgent: truncate(device.UserAgent, 512),
+		IpAddress: truncate(device.IpAddress, 64),
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return plain, nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(value string, size int) string {
+	if len(value) > size {
+		return value[:size]
+	}
+	return value
+}
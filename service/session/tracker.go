@@ -0,0 +1,43 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+// Tracker records when a session was last seen. A cache counter lets only
+// the first request of every interval write to the database, so last seen
+// is accurate to the interval.
+type Tracker struct {
+	db       *gorm.DB
+	cache    cache.Store
+	interval time.Duration
+}
+
+func NewTracker(db *gorm.DB, store cache.Store, interval time.Duration) *Tracker {
+	return &Tracker{
+		db:       db,
+		cache:    store,
+		interval: interval,
+	}
+}
+
+func (t *Tracker) Touch(ctx context.Context, sessionId string, ipAddress string) error {
+	if sessionId == "" {
+		return nil
+	}
+	hits, err := t.cache.IncrBy(ctx, "session:seen:"+sessionId, 1, t.interval)
+	if err != nil || hits > 1 {
+		return err
+	}
+	now := time.Now()
+	return repository.NewSessionRepository(t.db).Touch(sessionId, model.Session{
+		IpAddress:  truncate(ipAddress, 64),
+		LastSeenAt: &now,
+	}).Error
+}
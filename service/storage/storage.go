@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Storage keeps private objects in an S3 compatible bucket. Objects are
+// written through the internal endpoint, while download links are signed
+// for the external endpoint clients can reach.
+type Storage struct {
+	client    *s3.S3
+	presigner *s3.S3
+	bucket    string
+}
+
+func NewStorage(internal *session.Session, external *session.Session, bucket string) *Storage {
+	return &Storage{
+		client:    s3.New(internal),
+		presigner: s3.New(external),
+		bucket:    bucket,
+	}
+}
+
+func (s *Storage) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// PresignGet returns a download link valid for ttl.
+func (s *Storage) PresignGet(key string, ttl time.Duration) (string, error) {
+	req, _ := s.presigner.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(ttl)
+}
+
+// DeletePrefix removes every object whose key starts with prefix.
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	var deleteErr error
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		if len(page.Contents) == 0 {
+			return true
+		}
+		objects := make([]*s3.ObjectIdentifier, 0, len(page.Contents))
+		for _, v := range page.Contents {
+			objects = append(objects, &s3.ObjectIdentifier{Key: v.Key})
+		}
+		// a listed page holds at most 1000 keys, the DeleteObjects limit
+		_, deleteErr = s.client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		return deleteErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return deleteErr
+}
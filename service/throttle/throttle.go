@@ -0,0 +1,29 @@
+package throttle
+
+import (
+	"context"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+)
+
+// Throttle counts hits per key in fixed windows.
+type Throttle struct {
+	cache cache.Store
+}
+
+func NewThrottle(store cache.Store) *Throttle {
+	return &Throttle{
+		cache: store,
+	}
+}
+
+// Hit records one hit and reports whether the key is still within limit
+// hits for the current window.
+func (t *Throttle) Hit(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	count, err := t.cache.IncrBy(ctx, "throttle:"+key, 1, window)
+	if err != nil {
+		return false, err
+	}
+	return int(count) <= limit, nil
+}
@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+)
+
+var (
+	ErrInvalidChallenge = errors.New("invalid or expired challenge")
+	ErrTooManyAttempts  = errors.New("too many attempts, login again")
+)
+
+// Challenges hold the password step of a two step login. The token handed
+// out is opaque, only its hash is kept in the cache.
+type Challenges struct {
+	cache       cache.Store
+	ttl         time.Duration
+	maxAttempts int
+}
+
+func NewChallenges(store cache.Store, ttl time.Duration, maxAttempts int) *Challenges {
+	return &Challenges{
+		cache:       store,
+		ttl:         ttl,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (c *Challenges) TTL() time.Duration {
+	return c.ttl
+}
+
+// Issue a challenge for an account that passed the first factor.
+func (c *Challenges) Issue(ctx context.Context, accountId uint) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if err := c.cache.Set(ctx, challengeKey(token), strconv.FormatUint(uint64(accountId), 10), c.ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Attempt returns the account of the challenge and counts one attempt at
+// the second factor against it.
+func (c *Challenges) Attempt(ctx context.Context, token string) (uint, error) {
+	value, err := c.cache.Get(ctx, challengeKey(token))
+	if errors.Is(err, cache.ErrMiss) {
+		return 0, ErrInvalidChallenge
+	}
+	if err != nil {
+		return 0, err
+	}
+	attempts, err := c.cache.IncrBy(ctx, challengeKey(token)+":attempts", 1, c.ttl)
+	if err != nil {
+		return 0, err
+	}
+	if c.maxAttempts > 0 && attempts > int64(c.maxAttempts) {
+		c.cache.Delete(ctx, challengeKey(token))
+		return 0, ErrTooManyAttempts
+	}
+	accountId, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(accountId), nil
+}
+
+// Complete removes the challenge so its token can not be used again.
+func (c *Challenges) Complete(ctx context.Context, token string) error {
+	return c.cache.Delete(ctx, challengeKey(token))
+}
+
+func challengeKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "totp:challenge:" + hex.EncodeToString(sum[:])
+}
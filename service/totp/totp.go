@@ -0,0 +1,108 @@
+package totp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/service/cache"
+)
+
+// RFC 6238 defaults understood by every authenticator app
+const (
+	digits = 6
+	period = 30
+	// steps accepted before and after the current one, for clock drift
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random 160 bit secret, base32 encoded.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(buf), nil
+}
+
+// ProvisioningURI is the otpauth:// URI authenticator apps read from a QR
+// code.
+func ProvisioningURI(issuer string, accountName string, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(digits))
+	q.Set("period", strconv.Itoa(period))
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Code computes the code of a time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1000000), nil
+}
+
+// Validate returns the time step the code belongs to, or false when the
+// code does not match the current time.
+func Validate(secret string, code string, at time.Time) (int64, bool) {
+	current := at.Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Verifier validates codes and rejects a code that was already used, so an
+// observed code can not be replayed within its window.
+type Verifier struct {
+	cache cache.Store
+}
+
+func NewVerifier(store cache.Store) *Verifier {
+	return &Verifier{
+		cache: store,
+	}
+}
+
+func (v *Verifier) Verify(ctx context.Context, accountId uint, secret string, code string) (bool, error) {
+	step, ok := Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	key := fmt.Sprintf("totp:used:%d:%d", accountId, step)
+	hits, err := v.cache.IncrBy(ctx, key, 1, time.Duration(period*(2*skew+1))*time.Second)
+	if err != nil {
+		return false, err
+	}
+	return hits == 1, nil
+}
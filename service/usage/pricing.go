@@ -0,0 +1,28 @@
+package usage
+
+// Price per 1000 tokens of a model, in USD.
+type Price struct {
+	Model      string  `mapstructure:"model"`
+	Prompt     float64 `mapstructure:"prompt"`
+	Completion float64 `mapstructure:"completion"`
+}
+
+// Pricing maps a model name to its price.
+type Pricing map[string]Price
+
+func NewPricing(prices []Price) Pricing {
+	pricing := Pricing{}
+	for _, price := range prices {
+		pricing[price.Model] = price
+	}
+	return pricing
+}
+
+// Cost of a completion, models without a configured price cost nothing.
+func (p Pricing) Cost(model string, promptTokens int, completionTokens int) float64 {
+	price, ok := p[model]
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1000
+}
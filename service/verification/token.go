@@ -0,0 +1,69 @@
+package verification
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Issue creates a single-use token and returns the plain value to send to
+// the user. Only its SHA-256 hash is stored, and earlier unused tokens of the
+// same purpose are revoked.
+func Issue(db *gorm.DB, accountId uint, purpose string, target string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	plain := base64.RawURLEncoding.EncodeToString(buf)
+
+	tokenRepo := repository.NewVerificationTokenRepository(db)
+	if result := tokenRepo.RevokeByAccountIDAndPurpose(int(accountId), purpose); result.Error != nil {
+		return "", result.Error
+	}
+	if _, result := tokenRepo.Create(model.VerificationToken{
+		AccountID: accountId,
+		Purpose:   purpose,
+		TokenHash: Hash(plain),
+		Target:    target,
+		ExpiresAt: time.Now().Add(ttl),
+	}); result.Error != nil {
+		return "", result.Error
+	}
+	return plain, nil
+}
+
+// Consume marks the token used and returns it, ErrInvalidToken when it is
+// unknown, expired or already used.
+func Consume(db *gorm.DB, purpose string, plain string) (model.VerificationToken, error) {
+	tokenRepo := repository.NewVerificationTokenRepository(db)
+	token, result := tokenRepo.OneByPurposeAndHash(purpose, Hash(plain))
+	if result.Error != nil {
+		return token, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return token, ErrInvalidToken
+	}
+
+	result = tokenRepo.MarkUsed(int(token.ID))
+	if result.Error != nil {
+		return token, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return token, ErrInvalidToken
+	}
+	return token, nil
+}
+
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
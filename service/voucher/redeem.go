@@ -0,0 +1,90 @@
+package voucher
+
+import (
+	"errors"
+	"time"
+
+	"github.com/avarian/primbon-ajaib-backend/model"
+	"github.com/avarian/primbon-ajaib-backend/service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound          = errors.New("voucher not found")
+	ErrExpired           = errors.New("voucher expired")
+	ErrExhausted         = errors.New("voucher fully redeemed")
+	ErrPerUserLimit      = errors.New("voucher already redeemed")
+	ErrWrongType         = errors.New("voucher cannot be used here")
+	ErrPlanNotApplicable = errors.New("voucher not applicable to this plan")
+)
+
+// Redeem validates and claims a voucher for the account. It must run inside
+// a transaction: the voucher row is locked until commit so concurrent
+// redemptions cannot exceed MaxRedemptions or PerUserLimit.
+// orderCode links a discount redemption to its order, empty for free days.
+func Redeem(tx *gorm.DB, code string, account model.Account, voucherType string, planCode string, orderCode string) (model.Voucher, error) {
+	voucherRepo := repository.NewVoucherRepository(tx)
+	voucher, result := voucherRepo.OneByCodeForUpdate(code)
+	if result.Error != nil {
+		return voucher, result.Error
+	}
+	if result.RowsAffected == 0 || !voucher.IsActive {
+		return voucher, ErrNotFound
+	}
+	if voucher.Type != voucherType {
+		return voucher, ErrWrongType
+	}
+	if voucher.ExpiresAt != nil && time.Now().After(*voucher.ExpiresAt) {
+		return voucher, ErrExpired
+	}
+	if voucher.MaxRedemptions > 0 && voucher.RedemptionCount >= voucher.MaxRedemptions {
+		return voucher, ErrExhausted
+	}
+	if voucher.PlanCode != "" && planCode != "" && voucher.PlanCode != planCode {
+		return voucher, ErrPlanNotApplicable
+	}
+
+	redemptionRepo := repository.NewVoucherRedemptionRepository(tx)
+	if voucher.PerUserLimit > 0 {
+		count, result := redemptionRepo.CountByVoucherIDAndAccountID(int(voucher.ID), int(account.ID))
+		if result.Error != nil {
+			return voucher, result.Error
+		}
+		if int(count) >= voucher.PerUserLimit {
+			return voucher, ErrPerUserLimit
+		}
+	}
+
+	if _, result := redemptionRepo.Create(model.VoucherRedemption{
+		VoucherID: voucher.ID,
+		AccountID: account.ID,
+		OrderCode: orderCode,
+	}); result.Error != nil {
+		return voucher, result.Error
+	}
+	if result := voucherRepo.IncrementRedemption(int(voucher.ID), 1); result.Error != nil {
+		return voucher, result.Error
+	}
+	voucher.RedemptionCount++
+
+	return voucher, nil
+}
+
+// Release gives back the redemption claimed by an order that never got paid.
+func Release(tx *gorm.DB, orderCode string) error {
+	redemptionRepo := repository.NewVoucherRedemptionRepository(tx)
+	redemption, result := redemptionRepo.OneByOrderCode(orderCode)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	if result := redemptionRepo.Delete(int(redemption.ID), true); result.Error != nil {
+		return result.Error
+	}
+	return repository.NewVoucherRepository(tx).IncrementRedemption(int(redemption.VoucherID), -1).Error
+}
+
+// DiscountedAmount applies the voucher percentage to a price.
+func DiscountedAmount(voucher model.Voucher, price int64) int64 {
+	return price * int64(100-voucher.DiscountPercent) / 100
+}